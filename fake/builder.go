@@ -0,0 +1,175 @@
+package fake
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeLayout is the format used by 3scale backend for timestamps in usage reports
+const timeLayout = "2006-01-02 15:04:05 -0700"
+
+// AuthResponseBuilder builds the XML response returned by the authorization endpoints.
+// Use NewAuthSuccess or NewAuthFailure to create an AuthResponseBuilder
+type AuthResponseBuilder struct {
+	authorized   bool
+	reason       string
+	plan         string
+	usageReports []usageReport
+	hierarchy    []hierarchyMetric
+}
+
+type usageReport struct {
+	metric  string
+	period  string
+	start   time.Time
+	end     time.Time
+	max     int
+	current int
+}
+
+type hierarchyMetric struct {
+	parent   string
+	children []string
+}
+
+// NewAuthSuccess returns an AuthResponseBuilder for a successful authorization on the 'Basic' plan
+func NewAuthSuccess() *AuthResponseBuilder {
+	return &AuthResponseBuilder{authorized: true, plan: "Basic"}
+}
+
+// NewAuthFailure returns an AuthResponseBuilder for a denied authorization, on the 'Basic' plan, with the given reason
+func NewAuthFailure(reason string) *AuthResponseBuilder {
+	return &AuthResponseBuilder{reason: reason, plan: "Basic"}
+}
+
+// WithPlan sets the name of the application plan
+func (b *AuthResponseBuilder) WithPlan(plan string) *AuthResponseBuilder {
+	b.plan = plan
+	return b
+}
+
+// WithUsageReport adds a usage report for the metric for the limiting window of the given period which contains
+// the current time. period is one of the periods known to 3scale - for example "minute" or "eternity".
+// See WithUsageReportAt for a deterministic response.
+func (b *AuthResponseBuilder) WithUsageReport(metric string, period string, max int, current int) *AuthResponseBuilder {
+	return b.WithUsageReportAt(metric, period, time.Now().UTC(), max, current)
+}
+
+// WithUsageReportAt adds a usage report for the metric for the limiting window of the given period which contains
+// the time at. Windows are calculated in the location of at, with weeks starting on Monday.
+func (b *AuthResponseBuilder) WithUsageReportAt(metric string, period string, at time.Time, max int, current int) *AuthResponseBuilder {
+	start, end := periodWindow(period, at)
+	return b.WithUsageReportWindow(metric, period, start, end, max, current)
+}
+
+// WithUsageReportWindow adds a usage report for the metric with an explicit limiting window
+func (b *AuthResponseBuilder) WithUsageReportWindow(metric string, period string, start time.Time, end time.Time, max int, current int) *AuthResponseBuilder {
+	b.usageReports = append(b.usageReports, usageReport{
+		metric:  metric,
+		period:  period,
+		start:   start,
+		end:     end,
+		max:     max,
+		current: current,
+	})
+	return b
+}
+
+// WithHierarchy adds the children of the parent metric to the hierarchy returned by the hierarchy extension
+func (b *AuthResponseBuilder) WithHierarchy(parent string, children ...string) *AuthResponseBuilder {
+	b.hierarchy = append(b.hierarchy, hierarchyMetric{parent: parent, children: children})
+	return b
+}
+
+// XML returns the response body
+func (b *AuthResponseBuilder) XML() string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	sb.WriteString("<status>\n")
+	fmt.Fprintf(&sb, "  <authorized>%t</authorized>\n", b.authorized)
+	if b.reason != "" {
+		fmt.Fprintf(&sb, "  <reason>%s</reason>\n", escapeText(b.reason))
+	}
+	fmt.Fprintf(&sb, "  <plan>%s</plan>\n", escapeText(b.plan))
+
+	if len(b.usageReports) > 0 {
+		sb.WriteString("  <usage_reports>\n")
+		for _, report := range b.usageReports {
+			fmt.Fprintf(&sb, "    <usage_report metric=\"%s\" period=\"%s\">\n", escapeAttr(report.metric), escapeAttr(report.period))
+			if !report.start.IsZero() {
+				fmt.Fprintf(&sb, "      <period_start>%s</period_start>\n", report.start.Format(timeLayout))
+				fmt.Fprintf(&sb, "      <period_end>%s</period_end>\n", report.end.Format(timeLayout))
+			}
+			fmt.Fprintf(&sb, "      <max_value>%d</max_value>\n", report.max)
+			fmt.Fprintf(&sb, "      <current_value>%d</current_value>\n", report.current)
+			sb.WriteString("    </usage_report>\n")
+		}
+		sb.WriteString("  </usage_reports>\n")
+	}
+
+	if len(b.hierarchy) > 0 {
+		sb.WriteString("  <hierarchy>\n")
+		for _, metric := range b.hierarchy {
+			fmt.Fprintf(&sb, "    <metric name=\"%s\" children=\"%s\" />\n",
+				escapeAttr(metric.parent), escapeAttr(strings.Join(metric.children, " ")))
+		}
+		sb.WriteString("  </hierarchy>\n")
+	}
+
+	sb.WriteString("</status>")
+	return sb.String()
+}
+
+// NewErrorResp returns the XML error response returned by backend for the given error code and message
+func NewErrorResp(code string, message string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<error code="%s">%s</error>`, escapeAttr(code), escapeText(message))
+}
+
+// periodWindow returns the start and end of the limiting window for period which contains now.
+// Eternity, and any unknown period, returns zero times as the window is unbounded.
+func periodWindow(period string, now time.Time) (time.Time, time.Time) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch period {
+	case "second":
+		start := now.Truncate(time.Second)
+		return start, start.Add(time.Second)
+	case "minute":
+		start := now.Truncate(time.Minute)
+		return start, start.Add(time.Minute)
+	case "hour":
+		start := now.Truncate(time.Hour)
+		return start, start.Add(time.Hour)
+	case "day":
+		return day, day.AddDate(0, 0, 1)
+	case "week":
+		// weeks start on Monday
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7)
+	case "month":
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 1, 0)
+	case "year":
+		start := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(1, 0, 0)
+	default:
+		return time.Time{}, time.Time{}
+	}
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+// escapeText escapes s for use as character data. Quotes are left as is, matching the responses of backend.
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// escapeAttr escapes s for use as a double quoted attribute value
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
@@ -0,0 +1,117 @@
+package fake
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuthResponseBuilder_XML(t *testing.T) {
+	at := time.Date(2019, time.February, 22, 14, 32, 10, 0, time.UTC)
+
+	expect := `<?xml version="1.0" encoding="UTF-8"?>
+<status>
+  <authorized>false</authorized>
+  <reason>limits &lt;exceeded&gt; &amp; "denied"</reason>
+  <plan>Pro</plan>
+  <usage_reports>
+    <usage_report metric="hits" period="minute">
+      <period_start>2019-02-22 14:32:00 +0000</period_start>
+      <period_end>2019-02-22 14:33:00 +0000</period_end>
+      <max_value>4</max_value>
+      <current_value>4</current_value>
+    </usage_report>
+    <usage_report metric="hits" period="eternity">
+      <max_value>100</max_value>
+      <current_value>10</current_value>
+    </usage_report>
+  </usage_reports>
+  <hierarchy>
+    <metric name="hits" children="example sample" />
+    <metric name="a&quot;b" children="" />
+  </hierarchy>
+</status>`
+
+	actual := NewAuthFailure(`limits <exceeded> & "denied"`).
+		WithPlan("Pro").
+		WithUsageReportAt("hits", "minute", at, 4, 4).
+		WithUsageReportAt("hits", "eternity", at, 100, 10).
+		WithHierarchy("hits", "example", "sample").
+		WithHierarchy(`a"b`).
+		XML()
+
+	if actual != expect {
+		t.Errorf("unexpected response\nexpected:\n%s\ngot:\n%s", expect, actual)
+	}
+}
+
+func TestAuthResponseBuilder_Success(t *testing.T) {
+	expect := `<?xml version="1.0" encoding="UTF-8"?>
+<status>
+  <authorized>true</authorized>
+  <plan>Basic</plan>
+</status>`
+
+	if actual := NewAuthSuccess().XML(); actual != expect {
+		t.Errorf("unexpected response\nexpected:\n%s\ngot:\n%s", expect, actual)
+	}
+}
+
+func TestAuthResponseBuilder_WithUsageReport(t *testing.T) {
+	before := time.Now().UTC()
+	actual := NewAuthSuccess().WithUsageReport("hits", "minute", 100, 42).XML()
+	after := time.Now().UTC()
+
+	// the window containing the current time is reported, which may have moved on during the call
+	var matched bool
+	for _, at := range []time.Time{before, after} {
+		if actual == NewAuthSuccess().WithUsageReportAt("hits", "minute", at, 100, 42).XML() {
+			matched = true
+		}
+	}
+	if !matched {
+		t.Errorf("expected usage report for the current window but got\n%s", actual)
+	}
+}
+
+func TestPeriodWindow(t *testing.T) {
+	// a Sunday
+	now := time.Date(2020, time.March, 8, 13, 45, 30, 500, time.UTC)
+	date := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	}
+
+	inputs := []struct {
+		period     string
+		now        time.Time
+		start, end time.Time
+	}{
+		{period: "second", now: now, start: date(2020, time.March, 8, 13, 45, 30), end: date(2020, time.March, 8, 13, 45, 31)},
+		{period: "minute", now: now, start: date(2020, time.March, 8, 13, 45, 0), end: date(2020, time.March, 8, 13, 46, 0)},
+		{period: "hour", now: now, start: date(2020, time.March, 8, 13, 0, 0), end: date(2020, time.March, 8, 14, 0, 0)},
+		{period: "day", now: now, start: date(2020, time.March, 8, 0, 0, 0), end: date(2020, time.March, 9, 0, 0, 0)},
+		{period: "week", now: now, start: date(2020, time.March, 2, 0, 0, 0), end: date(2020, time.March, 9, 0, 0, 0)},
+		{period: "week", now: date(2020, time.March, 9, 0, 0, 0), start: date(2020, time.March, 9, 0, 0, 0), end: date(2020, time.March, 16, 0, 0, 0)},
+		{period: "week", now: date(2020, time.March, 4, 12, 0, 0), start: date(2020, time.March, 2, 0, 0, 0), end: date(2020, time.March, 9, 0, 0, 0)},
+		{period: "month", now: now, start: date(2020, time.March, 1, 0, 0, 0), end: date(2020, time.April, 1, 0, 0, 0)},
+		{period: "year", now: now, start: date(2020, time.January, 1, 0, 0, 0), end: date(2021, time.January, 1, 0, 0, 0)},
+		{period: "eternity", now: now},
+		{period: "unknown", now: now},
+	}
+
+	for _, input := range inputs {
+		start, end := periodWindow(input.period, input.now)
+		if !start.Equal(input.start) || !end.Equal(input.end) {
+			t.Errorf("unexpected window for %s at %s - expected %s to %s but got %s to %s",
+				input.period, input.now, input.start, input.end, start, end)
+		}
+	}
+}
+
+func TestNewErrorResp(t *testing.T) {
+	expect := `<?xml version="1.0" encoding="UTF-8"?>
+<error code="a&quot;b">metric "x" &lt;y&gt; &amp; z is invalid</error>`
+
+	if actual := NewErrorResp(`a"b`, `metric "x" <y> & z is invalid`); actual != expect {
+		t.Errorf("unexpected response\nexpected:\n%s\ngot:\n%s", expect, actual)
+	}
+}
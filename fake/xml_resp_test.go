@@ -0,0 +1,119 @@
+package fake
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// the fixtures below are the responses which were returned prior to the introduction of AuthResponseBuilder
+const (
+	authSuccessFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<status>
+  <authorized>true</authorized>
+  <plan>Basic</plan>
+</status>`
+
+	invalidIdOrTokenFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<error code="service_token_invalid">service token "token" or service id "id" is invalid</error>`
+
+	invalidMetricFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<error code="metric_invalid">metric "anyButHits" is invalid</error>`
+
+	invalidUserKeyFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<error code="user_key_invalid">user key "key" is invalid</error>`
+
+	limitExceededFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<status>
+  <authorized>false</authorized>
+  <reason>usage limits are exceeded</reason>
+  <plan>Basic</plan>
+  <usage_reports>
+    <usage_report metric="hits" period="minute">
+      <period_start>2018-09-01 14:44:00 +0000</period_start>
+      <period_end>2018-09-01 14:45:00 +0000</period_end>
+      <max_value>1</max_value>
+      <current_value>1</current_value>
+    </usage_report>
+  </usage_reports>
+</status>`
+
+	hierarchyEnabledFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<status>
+   <authorized>true</authorized>
+   <plan>Basic</plan>
+   <usage_reports>
+      <usage_report metric="hits" period="minute">
+         <period_start>2019-02-22 14:32:00 +0000</period_start>
+         <period_end>2019-02-22 14:33:00 +0000</period_end>
+         <max_value>4</max_value>
+         <current_value>1</current_value>
+      </usage_report>
+      <usage_report metric="test_metric" period="week">
+         <period_start>2019-02-18 00:00:00 +0000</period_start>
+         <period_end>2019-02-25 00:00:00 +0000</period_end>
+         <max_value>6</max_value>
+         <current_value>0</current_value>
+      </usage_report>
+   </usage_reports>
+   <hierarchy>
+      <metric name="hits" children="example sample test" />
+      <metric name="test_metric" children="" />
+   </hierarchy>
+</status>`
+)
+
+// node is a generic XML element used to compare documents regardless of formatting
+type node struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []node     `xml:",any"`
+}
+
+func parse(t *testing.T, doc string) node {
+	t.Helper()
+	var n node
+	if err := xml.Unmarshal([]byte(doc), &n); err != nil {
+		t.Fatalf("unexpected error parsing %s - %s", doc, err.Error())
+	}
+	return trim(n)
+}
+
+func trim(n node) node {
+	n.Text = strings.TrimSpace(n.Text)
+	for i := range n.Children {
+		n.Children[i] = trim(n.Children[i])
+	}
+	return n
+}
+
+func TestResponsesMatchFixtures(t *testing.T) {
+	inputs := []struct {
+		name    string
+		actual  string
+		fixture string
+		// identical is true where the output must match the fixture byte for byte
+		identical bool
+	}{
+		{name: "GetAuthSuccess", actual: GetAuthSuccess(), fixture: authSuccessFixture, identical: true},
+		{name: "GenInvalidIdOrTokenResp", actual: GenInvalidIdOrTokenResp("token", "id"), fixture: invalidIdOrTokenFixture, identical: true},
+		{name: "GetInvalidMetricResp", actual: GetInvalidMetricResp(), fixture: invalidMetricFixture, identical: true},
+		{name: "GenInvalidUserKey", actual: GenInvalidUserKey("key"), fixture: invalidUserKeyFixture, identical: true},
+		{name: "GetLimitExceededResp", actual: GetLimitExceededResp(), fixture: limitExceededFixture, identical: true},
+		{name: "GetHierarchyEnabledResponse", actual: GetHierarchyEnabledResponse(), fixture: hierarchyEnabledFixture},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			if input.identical && input.actual != input.fixture {
+				t.Errorf("expected response to match fixture\nexpected:\n%s\ngot:\n%s", input.fixture, input.actual)
+			}
+
+			if expect, actual := parse(t, input.fixture), parse(t, input.actual); !reflect.DeepEqual(expect, actual) {
+				t.Errorf("expected parsed response to match fixture\nexpected:\n%+v\ngot:\n%+v", expect, actual)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package api
 
+import "sync"
+
 const (
 	// ClientAuth authentication types
 
@@ -27,15 +29,28 @@ const (
 	// are calculated correctly. This feature is supported in versions >= 2.8
 	// Use the GetVersion() function to ensure suitability or risk incurring unreported state.
 	FlatUsageExtension = "flat_usage"
+
+	// RejectionReasonHeaderExtension is the key to enabling a header on authorization endpoints which provides
+	// an error code describing the reason an authorization has been denied - set to 1 to enable
+	RejectionReasonHeaderExtension = "rejection_reason_header"
+
+	// NoBodyExtension instructs backend to avoid generating response bodies for certain endpoints - set to 1 to enable
+	// In particular, this is useful to avoid generating large response in the authorization endpoints
+	NoBodyExtension = "no_body"
+
+	// extensionEnabled is the value used to enable a boolean extension
+	extensionEnabled = "1"
 )
 
 // Period wraps the known rate limiting periods as defined in 3scale
 type Period int
 
 // Predefined, known LimitPeriods which can be used in 3scale rate limiting functionality
-// These values represent time durations.
+// These values represent time durations. Second was added ahead of Minute, changing the values of the
+// periods which follow it, so periods should be referred to by constant or name rather than by value.
 const (
-	Minute Period = iota
+	Second Period = iota
+	Minute
 	Hour
 	Day
 	Week
@@ -59,12 +74,29 @@ type ClientAuth struct {
 // See https://github.com/3scale/apisonator/blob/v2.96.2/docs/extensions.md for context
 type Extensions map[string]string
 
+// ExtensionsBuilder provides typed setters for the known Extensions. Use NewExtensions to create an ExtensionsBuilder
+type ExtensionsBuilder struct {
+	extensions Extensions
+}
+
 // Hierarchy maps a parent metric to its child metrics
 type Hierarchy map[string][]string
 
-// Metrics let you track the usage of your API in 3scale
+// ReferrerWildcard is the special value for Params.Referrer which bypasses the referrer check
+const ReferrerWildcard = "*"
+
+// Metrics let you track the usage of your API in 3scale.
+// Metric names may contain any UTF-8 characters, including '.', '-' and '_', other than '[', ']', '&' and whitespace,
+// which cannot be safely represented in the 'usage[name]' form expected by backend - see ValidateNames.
 type Metrics map[string]int
 
+// SafeMetrics wraps Metrics, providing the same operations in a manner which is safe for concurrent use.
+// The zero value is ready to use. Use Snapshot to retrieve Metrics suitable for building a Transaction.
+type SafeMetrics struct {
+	mutex   sync.RWMutex
+	metrics Metrics
+}
+
 // Params that are embedded in each Transaction to 3scale API
 // This structure simplifies the formatting of the transaction from the callers perspective
 // It is used to authenticate the application
@@ -72,14 +104,17 @@ type Params struct {
 
 	// AppID is used in the Application Identifier and Key pairs authentication method.
 	// It is mutually exclusive with the API Key authentication method outlined below
-	// therefore if both are provided, the value defined in 'UserKey' will be prioritised.
+	// therefore providing both is invalid - see Validate.
 	AppID string `json:"app_id"`
 
-	// AppKey is an optional, secret key which can be used in conjunction with 'AppID'
+	// AppKey is an optional, secret key which can be used in conjunction with 'AppID'.
+	// An application may have multiple keys, however a single key is sent to backend - see AppKeys and WithAppKey.
 	AppKey string `json:"app_key"`
 
 	// Referrer is an optional value which is required only if referrer filtering is enabled.
 	// If special value '*' (wildcard) is passed, the referrer check is bypassed.
+	// Prefer SetReferrer, which validates the value, and only set this when referrer filtering is enabled
+	// for the application, as backend may otherwise reject the request.
 	Referrer string `json:"referrer"`
 
 	// UserID is an optional value for identifying an end user.
@@ -87,22 +122,22 @@ type Params struct {
 	UserID string `json:"user_id"`
 
 	// UserKey is the identifier and shared secret of the application if the authentication pattern is API Key.
-	// Mutually exclusive with, and prioritised over 'AppID'.
+	// Mutually exclusive with 'AppID'.
 	UserKey string `json:"user_key"`
 }
 
 // PeriodWindow holds information about the start and end time of the specified period
 // Start and End are unix timestamp
 type PeriodWindow struct {
-	Period Period
-	Start  int64
-	End    int64
+	Period Period `json:"period"`
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
 }
 
 // RateLimits holds the values returned when using rate limiting extension
 type RateLimits struct {
-	LimitRemaining int
-	LimitReset     int
+	LimitRemaining int `json:"limit_remaining"`
+	LimitReset     int `json:"limit_reset"`
 }
 
 // Service represents a 3scale service marked by its identifier (service_id)
@@ -114,15 +149,31 @@ type Transaction struct {
 	Metrics Metrics
 	Params  Params
 	// Timestamp is a unix timestamp.
-	// Timestamp will only be taken into account when calling the Report API
+	// Timestamp will only be taken into account when calling the Report and AuthRep APIs
 	Timestamp int64
+	// Log is optional and will only be taken into account when calling the Report and AuthRep APIs
+	Log Log
 }
 
+// Log holds the details of an API call which are recorded by 3scale and displayed in the traffic log
+// of the application. Empty fields are not sent.
+type Log struct {
+	// Request is a description of the request, for example its method and path
+	Request string
+	// Response is a description of the response, for example its body
+	Response string
+	// Code is the HTTP status code of the response
+	Code int
+}
+
+// TransactionOption configures a Transaction when using NewTransaction
+type TransactionOption func(*Transaction) error
+
 // UsageReport for rate limiting information gathered from using extensions
 type UsageReport struct {
-	PeriodWindow PeriodWindow
-	MaxValue     int
-	CurrentValue int
+	PeriodWindow PeriodWindow `json:"period_window"`
+	MaxValue     int          `json:"max_value"`
+	CurrentValue int          `json:"current_value"`
 }
 
 // UsageReports defines a map of metric names to a list of 'UsageReport'
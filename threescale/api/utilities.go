@@ -1,10 +1,61 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
 	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
+// NewExtensions returns an ExtensionsBuilder which can be used to build Extensions without
+// the need to remember the keys and values expected by backend
+func NewExtensions() *ExtensionsBuilder {
+	return &ExtensionsBuilder{extensions: make(Extensions)}
+}
+
+// WithLimitHeaders enables the rate limiting extension
+func (eb *ExtensionsBuilder) WithLimitHeaders() *ExtensionsBuilder {
+	return eb.enable(LimitExtension)
+}
+
+// WithHierarchy enables the hierarchy extension
+func (eb *ExtensionsBuilder) WithHierarchy() *ExtensionsBuilder {
+	return eb.enable(HierarchyExtension)
+}
+
+// WithFlatUsage enables the flat usage extension
+func (eb *ExtensionsBuilder) WithFlatUsage() *ExtensionsBuilder {
+	return eb.enable(FlatUsageExtension)
+}
+
+// WithRejectionReasonHeader enables the rejection reason header extension
+func (eb *ExtensionsBuilder) WithRejectionReasonHeader() *ExtensionsBuilder {
+	return eb.enable(RejectionReasonHeaderExtension)
+}
+
+// WithNoBody enables the no body extension
+func (eb *ExtensionsBuilder) WithNoBody() *ExtensionsBuilder {
+	return eb.enable(NoBodyExtension)
+}
+
+// Build returns the Extensions. The builder can continue to be used without affecting the returned value.
+func (eb *ExtensionsBuilder) Build() Extensions {
+	extensions := make(Extensions, len(eb.extensions))
+	for k, v := range eb.extensions {
+		extensions[k] = v
+	}
+	return extensions
+}
+
+func (eb *ExtensionsBuilder) enable(key string) *ExtensionsBuilder {
+	eb.extensions[key] = extensionEnabled
+	return eb
+}
+
 // DeepCopy returns a clone of the original Metrics. It provides a deep copy
 // of both the key and the value of the original Hierarchy.
 func (h Hierarchy) DeepCopy() Hierarchy {
@@ -62,11 +113,27 @@ func (m Metrics) SubtractHierarchyFromMetrics(hierarchy Hierarchy) Metrics {
 	return metrics
 }
 
+// NewMetrics returns a copy of the provided map as Metrics, validating that all values are non-negative.
+// This should be used when constructing Metrics from external input.
+func NewMetrics(m map[string]int) (Metrics, error) {
+	metrics := make(Metrics, len(m))
+	for name, value := range m {
+		if err := metrics.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	return metrics, nil
+}
+
 // Add takes a provided key and value and adds them to the Metric 'm'
 // If the metric already existed in 'm', then the value will be added (if positive) or subtracted (if negative) from the existing value.
 // If a subtraction leads to a negative value Add returns an error  and the change will be discarded.
 // Returns the updated value (or current value in error cases) as well as the error.
 func (m Metrics) Add(name string, value int) (int, error) {
+	if err := validateMetricName(name); err != nil {
+		return m[name], err
+	}
+
 	if currentValue, ok := m[name]; ok {
 		newValue := currentValue + value
 		if newValue < 0 {
@@ -81,6 +148,10 @@ func (m Metrics) Add(name string, value int) (int, error) {
 
 // Set takes a provided key and value and sets that value of the key in 'm', overwriting any value that exists previously.
 func (m Metrics) Set(name string, value int) error {
+	if err := validateMetricName(name); err != nil {
+		return err
+	}
+
 	if value < 0 {
 		return fmt.Errorf("invalid value for metric %s post computation. this will result in 403 from 3scale", name)
 	}
@@ -88,6 +159,35 @@ func (m Metrics) Set(name string, value int) error {
 	return nil
 }
 
+// SetRaw sets the value of the metric in 'm' as per Set, but permits negative values. This allows a corrective report
+// to be sent, adjusting usage previously reported downward. Negative usage is only accepted by backend where it has
+// been configured to do so and is otherwise rejected. Negative values are discarded from reports made by a client
+// configured to report only positive deltas.
+func (m Metrics) SetRaw(name string, value int) error {
+	if err := validateMetricName(name); err != nil {
+		return err
+	}
+	m[name] = value
+	return nil
+}
+
+// ValidateNames ensures that every metric name in 'm' can be safely encoded in a request to 3scale.
+// Names must not be empty or contain '[', ']', '&' or whitespace.
+func (m Metrics) ValidateNames() error {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := validateMetricName(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Delete a metric m['name'] if present
 func (m Metrics) Delete(name string) {
 	delete(m, name)
@@ -102,9 +202,290 @@ func (m Metrics) DeepCopy() Metrics {
 	return clone
 }
 
+// Subtract returns a new Metrics holding the values of 'm' less those of 'other', leaving both untouched.
+// Values are clamped at zero and metrics which reach zero are omitted, so subtracting the metrics which have been
+// reported from a local accumulator leaves only the usage which remains to be reported.
+func (m Metrics) Subtract(other Metrics) Metrics {
+	result := make(Metrics, len(m))
+	for name, value := range m {
+		if remaining := value - other[name]; remaining > 0 {
+			result[name] = remaining
+		}
+	}
+	return result
+}
+
+// IsEmpty returns true if 'm' holds no metrics with a non-zero value
+func (m Metrics) IsEmpty() bool {
+	for _, value := range m {
+		if value != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Add provides the behaviour of Metrics.Add in a concurrency safe manner
+func (sm *SafeMetrics) Add(name string, value int) (int, error) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	return sm.unsafeMetrics().Add(name, value)
+}
+
+// Set provides the behaviour of Metrics.Set in a concurrency safe manner
+func (sm *SafeMetrics) Set(name string, value int) error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	return sm.unsafeMetrics().Set(name, value)
+}
+
+// Delete provides the behaviour of Metrics.Delete in a concurrency safe manner
+func (sm *SafeMetrics) Delete(name string) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.unsafeMetrics().Delete(name)
+}
+
+// DeepCopy returns a clone of the original SafeMetrics
+func (sm *SafeMetrics) DeepCopy() *SafeMetrics {
+	return &SafeMetrics{metrics: sm.Snapshot()}
+}
+
+// Snapshot returns a copy of the current state as Metrics
+func (sm *SafeMetrics) Snapshot() Metrics {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+	return sm.metrics.DeepCopy()
+}
+
+// unsafeMetrics lazily initialises the underlying Metrics - callers must hold the write lock
+func (sm *SafeMetrics) unsafeMetrics() Metrics {
+	if sm.metrics == nil {
+		sm.metrics = make(Metrics)
+	}
+	return sm.metrics
+}
+
+// Validate the Params identify an application. Exactly one of UserKey or AppID must be provided and
+// the Referrer, if provided, must not be blank.
+func (p Params) Validate() error {
+	if p.UserKey != "" && p.AppID != "" {
+		return fmt.Errorf("user_key and app_id are mutually exclusive")
+	}
+
+	if p.UserKey == "" && p.AppID == "" {
+		return fmt.Errorf("one of user_key or app_id must be provided")
+	}
+
+	if p.Referrer != "" && strings.TrimSpace(p.Referrer) == "" {
+		return fmt.Errorf("referrer must not be blank")
+	}
+	return nil
+}
+
+// AppKeys returns the application keys held by AppKey, which may provide multiple keys as a comma separated list.
+// Surrounding whitespace and empty entries are discarded. Returns nil if no key has been provided.
+func (p Params) AppKeys() []string {
+	var keys []string
+	for _, key := range strings.Split(p.AppKey, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// WithAppKey returns a copy of the Params with AppKey set to the provided key, for example one selected from AppKeys
+func (p Params) WithAppKey(key string) Params {
+	p.AppKey = key
+	return p
+}
+
+// SetReferrer sets the referrer to the provided value, which must be a hostname, an IP address or the wildcard '*'
+// which bypasses the referrer check. Returns an error, leaving the referrer unchanged, if the value is invalid.
+func (p *Params) SetReferrer(value string) error {
+	if value != ReferrerWildcard && net.ParseIP(value) == nil && !isHostname(value) {
+		return fmt.Errorf("invalid referrer %q - must be a hostname, an IP address or %q", value, ReferrerWildcard)
+	}
+	p.Referrer = value
+	return nil
+}
+
+// ClearReferrer removes the referrer so that it is not sent to backend
+func (p *Params) ClearReferrer() {
+	p.Referrer = ""
+}
+
+// isHostname returns true if the value is a valid hostname as defined by RFC 1123
+func isHostname(value string) bool {
+	value = strings.TrimSuffix(value, ".")
+	if value == "" || len(value) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(value, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NewTransaction returns a Transaction for the provided Params, configured by the provided options.
+// Returns an error if any of the options are invalid.
+func NewTransaction(params Params, options ...TransactionOption) (Transaction, error) {
+	transaction := Transaction{Params: params}
+	for _, option := range options {
+		if err := option(&transaction); err != nil {
+			return Transaction{}, err
+		}
+	}
+	return transaction, nil
+}
+
+// WithMetrics sets a copy of the provided Metrics on the Transaction, validating that all values are non-negative
+func WithMetrics(metrics Metrics) TransactionOption {
+	return func(t *Transaction) error {
+		m, err := NewMetrics(metrics)
+		if err != nil {
+			return err
+		}
+		t.Metrics = m
+		return nil
+	}
+}
+
+// WithTimestamp sets the unix timestamp on the Transaction. The timestamp must not be negative.
+func WithTimestamp(timestamp int64) TransactionOption {
+	return func(t *Transaction) error {
+		if timestamp < 0 {
+			return fmt.Errorf("invalid timestamp %d - timestamp must not be negative", timestamp)
+		}
+		t.Timestamp = timestamp
+		return nil
+	}
+}
+
+// CollapseTransactions merges transactions which share the same Params, Timestamp and Log into a single transaction,
+// summing the values of their metrics. This reduces the size of a report where many hits have been accumulated
+// for the same application. The order in which each distinct transaction first appears is preserved and the
+// provided transactions are left untouched. See CollapseTransactionsWithin to merge across a window of time.
+func CollapseTransactions(transactions []Transaction) []Transaction {
+	return CollapseTransactionsWithin(transactions, 0)
+}
+
+// CollapseTransactionsWithin provides the behaviour of CollapseTransactions, additionally merging transactions whose
+// timestamps fall within the same interval, aligned to the unix epoch. The merged transaction has the timestamp of
+// the start of the interval. Transactions without a timestamp are only merged with each other.
+// An interval of less than one second requires timestamps to match exactly.
+func CollapseTransactionsWithin(transactions []Transaction, interval time.Duration) []Transaction {
+	type key struct {
+		params    Params
+		timestamp int64
+		log       Log
+	}
+
+	bucket := int64(interval / time.Second)
+	indexes := make(map[key]int, len(transactions))
+	collapsed := make([]Transaction, 0, len(transactions))
+
+	for _, transaction := range transactions {
+		timestamp := transaction.Timestamp
+		if bucket > 1 && timestamp > 0 {
+			timestamp -= timestamp % bucket
+		}
+
+		k := key{params: transaction.Params, timestamp: timestamp, log: transaction.Log}
+		i, ok := indexes[k]
+		if !ok {
+			indexes[k] = len(collapsed)
+			collapsed = append(collapsed, Transaction{
+				Params:    transaction.Params,
+				Metrics:   transaction.Metrics.DeepCopy(),
+				Timestamp: timestamp,
+				Log:       transaction.Log,
+			})
+			continue
+		}
+
+		for name, value := range transaction.Metrics {
+			collapsed[i].Metrics[name] += value
+		}
+	}
+	return collapsed
+}
+
+// periodNames are the names used by 3scale for each Period, indexed by Period
+var periodNames = [...]string{"second", "minute", "hour", "day", "week", "month", "year", "eternity"}
+
+// periodsByName maps the name used by 3scale for a period to the Period
+var periodsByName = map[string]Period{
+	"second":   Second,
+	"minute":   Minute,
+	"hour":     Hour,
+	"day":      Day,
+	"week":     Week,
+	"month":    Month,
+	"year":     Year,
+	"eternity": Eternity,
+}
+
+// legacyPeriods maps the integer values of periods encoded to JSON before Second was introduced,
+// and periods were encoded as strings, to the Period
+var legacyPeriods = map[int]Period{
+	0: Minute,
+	1: Hour,
+	2: Day,
+	3: Week,
+	4: Month,
+	5: Year,
+	6: Eternity,
+}
+
 // String returns a string representation of the Period
 func (p Period) String() string {
-	return [...]string{"minute", "hour", "day", "week", "month", "year", "eternity"}[p]
+	return periodNames[p]
+}
+
+// MarshalJSON encodes the Period as its string representation, for example "minute"
+func (p Period) MarshalJSON() ([]byte, error) {
+	if p < Second || p > Eternity {
+		return nil, fmt.Errorf("cannot marshal unknown period %d", p)
+	}
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON decodes a Period from its string representation. For compatibility with JSON produced
+// prior to periods being encoded as strings, integers are also accepted. Such integers were produced before
+// Second was introduced, when Minute had the value 0, so are mapped to the period they represented then.
+func (p *Period) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		var value int
+		if intErr := json.Unmarshal(data, &value); intErr != nil {
+			return fmt.Errorf("invalid period %s - %s", data, err.Error())
+		}
+
+		period, ok := legacyPeriods[value]
+		if !ok {
+			return fmt.Errorf("unknown legacy period %d", value)
+		}
+		*p = period
+		return nil
+	}
+
+	period, ok := periodsByName[name]
+	if !ok {
+		return fmt.Errorf("unknown period %q", name)
+	}
+	*p = period
+	return nil
 }
 
 // IsEqual compares two PeriodWindows. They are equal if the period is the same
@@ -116,6 +497,25 @@ func (pw PeriodWindow) IsEqual(window PeriodWindow) bool {
 	return true
 }
 
+// Contains returns true if the provided time falls within the window, inclusive of Start and exclusive of End.
+// Eternity windows are unbounded and therefore always contain the provided time.
+func (pw PeriodWindow) Contains(t time.Time) bool {
+	if pw.Period == Eternity {
+		return true
+	}
+	unix := t.Unix()
+	return unix >= pw.Start && unix < pw.End
+}
+
+// Duration returns the length of time spanned by the window.
+// Eternity windows are unbounded and will return zero.
+func (pw PeriodWindow) Duration() time.Duration {
+	if pw.Period == Eternity {
+		return 0
+	}
+	return time.Duration(pw.End-pw.Start) * time.Second
+}
+
 func (ur UsageReport) IsForEternity() bool {
 	if ur.PeriodWindow.Period != Eternity {
 		return false
@@ -165,6 +565,13 @@ func (ur UsageReport) IsForMinute() bool {
 	return true
 }
 
+func (ur UsageReport) IsForSecond() bool {
+	if ur.PeriodWindow.Period != Second {
+		return false
+	}
+	return true
+}
+
 // IsSame does a comparison of two usage reports. They are considered the same only if their PeriodWindows are equal
 // and the max value for the limit has not changed. Current limit values are ignored.
 func (ur UsageReport) IsSame(usageReport UsageReport) bool {
@@ -179,7 +586,114 @@ func (ur UsageReport) IsSame(usageReport UsageReport) bool {
 	return true
 }
 
+// isExhaustedAt returns true if the limiting window is active at the time provided and its limit has been reached.
+// Eternity windows are always active. A negative MaxValue imposes no limit so is never exhausted, whereas a
+// MaxValue of 0 disables the metric, with backend denying every call, so is always exhausted.
+func (ur UsageReport) isExhaustedAt(now time.Time) bool {
+	if ur.MaxValue < 0 {
+		return false
+	}
+
+	if !ur.IsForEternity() && now.Unix() >= ur.PeriodWindow.End {
+		return false
+	}
+	return ur.CurrentValue >= ur.MaxValue
+}
+
+// IsUnlimited returns true if the usage report does not impose a limit. Backend uses a non-positive
+// MaxValue as a sentinel for this case.
+func (ur UsageReport) IsUnlimited() bool {
+	return ur.MaxValue <= 0
+}
+
+// Remaining returns the remaining quota for the limiting window, clamped at 0.
+// Returns -1 if the usage report is unlimited.
+func (ur UsageReport) Remaining() int {
+	if ur.IsUnlimited() {
+		return -1
+	}
+
+	if ur.CurrentValue >= ur.MaxValue {
+		return 0
+	}
+	return ur.MaxValue - ur.CurrentValue
+}
+
+// PercentUsed returns the percentage of the quota which has been consumed for the limiting window.
+// Returns -1 if the usage report is unlimited.
+func (ur UsageReport) PercentUsed() float64 {
+	if ur.IsUnlimited() {
+		return -1
+	}
+	return float64(ur.CurrentValue) / float64(ur.MaxValue) * 100
+}
+
+// IsCurrent returns true if the limiting window of the usage report is active at the time provided, inclusive of
+// the start of the window and exclusive of its end. Usage reports for Eternity are always current.
+// A usage report which is no longer current, for example one which has been cached, no longer reflects the limits.
+func (ur UsageReport) IsCurrent(now time.Time) bool {
+	return ur.PeriodWindow.Contains(now)
+}
+
+// RetryAfter returns the length of time from now until the limiting window resets, suitable for use as the value of
+// a 'Retry-After' header. Returns zero if the window has already elapsed, or is for Eternity and therefore never resets.
+func (ur UsageReport) RetryAfter(now time.Time) time.Duration {
+	if ur.IsForEternity() {
+		return 0
+	}
+
+	retryAfter := time.Unix(ur.PeriodWindow.End, 0).Sub(now)
+	if retryAfter < 0 {
+		return 0
+	}
+	return retryAfter
+}
+
+// MostConstrained returns the usage report for the metric whose limiting window is closest to being exhausted,
+// as determined by PercentUsed. Where windows are equally constrained, the window which resets last is returned.
+// Unlimited usage reports are ignored. Returns false if the metric has no limited usage reports.
+func (urs UsageReports) MostConstrained(metric string) (UsageReport, bool) {
+	var mostConstrained UsageReport
+	var found bool
+	for _, report := range urs[metric] {
+		if report.IsUnlimited() {
+			continue
+		}
+
+		if !found {
+			mostConstrained, found = report, true
+			continue
+		}
+
+		used, mostUsed := report.PercentUsed(), mostConstrained.PercentUsed()
+		if used > mostUsed || (used == mostUsed && resetsAfter(report, mostConstrained)) {
+			mostConstrained = report
+		}
+	}
+	return mostConstrained, found
+}
+
+// resetsAfter returns true if the window for usage report a resets after that of b
+func resetsAfter(a UsageReport, b UsageReport) bool {
+	if a.IsForEternity() || b.IsForEternity() {
+		return a.IsForEternity() && !b.IsForEternity()
+	}
+	return a.PeriodWindow.End > b.PeriodWindow.End
+}
+
+// DeepCopy returns a clone of the original UsageReports
+func (urs UsageReports) DeepCopy() UsageReports {
+	clone := make(UsageReports, len(urs))
+	for k, v := range urs {
+		var clonedV []UsageReport
+		clonedV = append(clonedV, v...)
+		clone[k] = clonedV
+	}
+	return clone
+}
+
 // OrderByAscendingGranularity sorts each slice in the usage reports in order of ascending granularity
+// The usage reports are sorted in place. See SortedByAscendingGranularity to leave the original untouched.
 func (urs UsageReports) OrderByAscendingGranularity() {
 	for _, reports := range urs {
 		sort.SliceStable(reports, func(i, j int) bool {
@@ -189,6 +703,7 @@ func (urs UsageReports) OrderByAscendingGranularity() {
 }
 
 // OrderByDescendingGranularity sorts each slice in the usage reports in order of descending granularity
+// The usage reports are sorted in place. See SortedByDescendingGranularity to leave the original untouched.
 func (urs UsageReports) OrderByDescendingGranularity() {
 	for _, reports := range urs {
 		sort.SliceStable(reports, func(i, j int) bool {
@@ -197,6 +712,94 @@ func (urs UsageReports) OrderByDescendingGranularity() {
 	}
 }
 
+// SortedByAscendingGranularity returns a copy of the usage reports with each slice sorted in order of ascending granularity
+// leaving the original usage reports untouched
+func (urs UsageReports) SortedByAscendingGranularity() UsageReports {
+	clone := urs.DeepCopy()
+	clone.OrderByAscendingGranularity()
+	return clone
+}
+
+// SortedByDescendingGranularity returns a copy of the usage reports with each slice sorted in order of descending granularity
+// leaving the original usage reports untouched
+func (urs UsageReports) SortedByDescendingGranularity() UsageReports {
+	clone := urs.DeepCopy()
+	clone.OrderByDescendingGranularity()
+	return clone
+}
+
+// FilterByPeriod returns a copy of the usage reports containing only the reports for the period provided,
+// leaving the original usage reports untouched. Metrics which have no report for the period are omitted.
+func (urs UsageReports) FilterByPeriod(p Period) UsageReports {
+	filtered := make(UsageReports)
+	for metric, reports := range urs {
+		for _, report := range reports {
+			if report.PeriodWindow.Period == p {
+				filtered[metric] = append(filtered[metric], report)
+			}
+		}
+	}
+	return filtered
+}
+
+// ShouldThrottle returns true if any limiting window for the metric, which is active at the time provided, has been exhausted.
+// Where a metric has multiple windows the most restrictive applies. Windows that have elapsed are ignored, with the exception
+// of Eternity windows which never reset. Windows with a negative MaxValue are ignored, while a MaxValue of 0 disables
+// the metric and always throttles.
+func (urs UsageReports) ShouldThrottle(metric string, now time.Time) bool {
+	for _, report := range urs[metric] {
+		if report.isExhaustedAt(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextResetAt returns the time at which the most restrictive window for the metric, which is active and exhausted
+// now, resets. See NextResetAtTime.
+func (urs UsageReports) NextResetAt(metric string) time.Time {
+	return urs.NextResetAtTime(metric, time.Now())
+}
+
+// NextResetAtTime returns the time at which the most restrictive window for the metric, which is active and exhausted
+// at the time provided, resets. Returns the zero time if no such window exists, or if an exhausted window is for
+// Eternity and therefore never resets. Windows are considered as per ShouldThrottle.
+func (urs UsageReports) NextResetAtTime(metric string, now time.Time) time.Time {
+	var resetAt int64
+	for _, report := range urs[metric] {
+		if !report.isExhaustedAt(now) {
+			continue
+		}
+
+		if report.IsForEternity() {
+			return time.Time{}
+		}
+
+		if report.PeriodWindow.End > resetAt {
+			resetAt = report.PeriodWindow.End
+		}
+	}
+
+	if resetAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(resetAt, 0)
+}
+
+func validateMetricName(name string) error {
+	if name == "" {
+		return fmt.Errorf("invalid metric name - name must not be empty")
+	}
+
+	if i := strings.IndexFunc(name, func(r rune) bool {
+		return r == '[' || r == ']' || r == '&' || unicode.IsSpace(r)
+	}); i != -1 {
+		r, _ := utf8.DecodeRuneInString(name[i:])
+		return fmt.Errorf("invalid metric name %q - character %q is not allowed", name, r)
+	}
+	return nil
+}
+
 func contains(key string, in []string) bool {
 	for _, i := range in {
 		if key == i {
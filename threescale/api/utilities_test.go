@@ -1,10 +1,42 @@
 package api
 
 import (
+	"encoding/json"
+	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
+func TestNewExtensions(t *testing.T) {
+	builder := NewExtensions().
+		WithLimitHeaders().
+		WithHierarchy().
+		WithFlatUsage().
+		WithRejectionReasonHeader().
+		WithNoBody()
+
+	expect := Extensions{
+		"limit_headers":           "1",
+		"hierarchy":               "1",
+		"flat_usage":              "1",
+		"rejection_reason_header": "1",
+		"no_body":                 "1",
+	}
+
+	got := builder.Build()
+	if !reflect.DeepEqual(expect, got) {
+		t.Errorf("unexpected extensions, wanted %v but got %v", expect, got)
+	}
+
+	got["limit_headers"] = "0"
+	if builder.Build()["limit_headers"] != "1" {
+		t.Error("expected built extensions to be independent of the builder")
+	}
+}
+
 func TestHierarchy_DeepCopy(t *testing.T) {
 	h := make(Hierarchy)
 	h["hits"] = []string{"x", "y", "z"}
@@ -17,6 +49,227 @@ func TestHierarchy_DeepCopy(t *testing.T) {
 	}
 }
 
+func TestNewMetrics(t *testing.T) {
+	input := map[string]int{"hits": 1, "other": 0}
+	m, err := NewMetrics(input)
+	if err != nil {
+		t.Error("unexpected error")
+	}
+
+	if !reflect.DeepEqual(Metrics(input), m) {
+		t.Errorf("unexpected metrics, expected %v, but got %v", input, m)
+	}
+
+	m["hits"] = 5
+	if input["hits"] != 1 {
+		t.Error("expected changes in returned metrics to not modify input")
+	}
+
+	m, err = NewMetrics(map[string]int{"hits": 1, "negative": -1})
+	if err == nil {
+		t.Error("expected error but got none")
+	}
+
+	if m != nil {
+		t.Error("expected nil metrics on error")
+	}
+}
+
+func TestParams_AppKeys(t *testing.T) {
+	input := []struct {
+		appKey string
+		expect []string
+	}{
+		{appKey: "", expect: nil},
+		{appKey: "key", expect: []string{"key"}},
+		{appKey: "one,two", expect: []string{"one", "two"}},
+		{appKey: " one , ,two, ", expect: []string{"one", "two"}},
+	}
+
+	for _, test := range input {
+		p := Params{AppID: "id", AppKey: test.appKey}
+		if got := p.AppKeys(); !reflect.DeepEqual(got, test.expect) {
+			t.Errorf("unexpected keys for %q, wanted %v but got %v", test.appKey, test.expect, got)
+		}
+	}
+
+	original := Params{AppID: "id", AppKey: "one,two"}
+	selected := original.WithAppKey("two")
+	if selected.AppKey != "two" || selected.AppID != "id" || original.AppKey != "one,two" {
+		t.Errorf("unexpected params after selecting key, got %v from %v", selected, original)
+	}
+}
+
+func TestParams_SetReferrer(t *testing.T) {
+	input := []struct {
+		name      string
+		referrer  string
+		expectErr bool
+	}{
+		{name: "Test wildcard", referrer: "*"},
+		{name: "Test hostname", referrer: "www.example.com"},
+		{name: "Test single label hostname", referrer: "localhost"},
+		{name: "Test fully qualified hostname", referrer: "example.com."},
+		{name: "Test IPv4", referrer: "192.168.0.1"},
+		{name: "Test IPv6", referrer: "::1"},
+		{name: "Test empty", referrer: "", expectErr: true},
+		{name: "Test URL", referrer: "https://example.com/path", expectErr: true},
+		{name: "Test partial wildcard", referrer: "*.example.com", expectErr: true},
+		{name: "Test invalid label", referrer: "-example.com", expectErr: true},
+		{name: "Test empty label", referrer: "example..com", expectErr: true},
+		{name: "Test whitespace", referrer: "example .com", expectErr: true},
+	}
+
+	for _, test := range input {
+		t.Run(test.name, func(t *testing.T) {
+			p := Params{Referrer: "existing"}
+			err := p.SetReferrer(test.referrer)
+			if test.expectErr {
+				if err == nil {
+					t.Errorf("expected error for referrer %q", test.referrer)
+				}
+				if p.Referrer != "existing" {
+					t.Errorf("expected referrer to be unchanged but got %q", p.Referrer)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error - %s", err.Error())
+			}
+			if p.Referrer != test.referrer {
+				t.Errorf("unexpected referrer, wanted %q but got %q", test.referrer, p.Referrer)
+			}
+
+			p.ClearReferrer()
+			if p.Referrer != "" {
+				t.Errorf("expected referrer to be cleared but got %q", p.Referrer)
+			}
+		})
+	}
+}
+
+func TestParams_Validate(t *testing.T) {
+	input := []struct {
+		name      string
+		params    Params
+		expectErr bool
+	}{
+		{
+			name:   "Test user key",
+			params: Params{UserKey: "key", Referrer: "*"},
+		},
+		{
+			name:   "Test app id and key",
+			params: Params{AppID: "id", AppKey: "key"},
+		},
+		{
+			name:      "Test user key and app id are mutually exclusive",
+			params:    Params{UserKey: "key", AppID: "id"},
+			expectErr: true,
+		},
+		{
+			name:      "Test application must be identified",
+			params:    Params{UserID: "user"},
+			expectErr: true,
+		},
+		{
+			name:      "Test blank referrer",
+			params:    Params{UserKey: "key", Referrer: "  "},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range input {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.params.Validate()
+			if test.expectErr != (err != nil) {
+				t.Errorf("unexpected result, expected error %v but got %v", test.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestNewTransaction(t *testing.T) {
+	params := Params{UserKey: "key"}
+
+	transaction, err := NewTransaction(params, WithMetrics(Metrics{"hits": 1}), WithTimestamp(1000))
+	if err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	expect := Transaction{Params: params, Metrics: Metrics{"hits": 1}, Timestamp: 1000}
+	if !reflect.DeepEqual(expect, transaction) {
+		t.Errorf("unexpected transaction, wanted %v but got %v", expect, transaction)
+	}
+
+	if _, err := NewTransaction(params, WithMetrics(Metrics{"hits": -1})); err == nil {
+		t.Error("expected error for negative metric value")
+	}
+
+	if _, err := NewTransaction(params, WithTimestamp(-1)); err == nil {
+		t.Error("expected error for negative timestamp")
+	}
+}
+
+func TestCollapseTransactions(t *testing.T) {
+	app := Params{AppID: "app"}
+	other := Params{AppID: "app", UserID: "user"}
+
+	transactions := []Transaction{
+		{Params: app, Metrics: Metrics{"hits": 1, "orders": 2}},
+		{Params: other, Metrics: Metrics{"hits": 1}},
+		{Params: app, Metrics: Metrics{"hits": 3, "searches": 4}},
+		{Params: app, Metrics: Metrics{"hits": 1}, Timestamp: 1000},
+		{Params: other, Metrics: Metrics{"downloads": 5}},
+	}
+
+	expect := []Transaction{
+		{Params: app, Metrics: Metrics{"hits": 4, "orders": 2, "searches": 4}},
+		{Params: other, Metrics: Metrics{"hits": 1, "downloads": 5}},
+		{Params: app, Metrics: Metrics{"hits": 1}, Timestamp: 1000},
+	}
+
+	got := CollapseTransactions(transactions)
+	if !reflect.DeepEqual(expect, got) {
+		t.Errorf("unexpected result, wanted %v but got %v", expect, got)
+	}
+
+	if !reflect.DeepEqual(Metrics{"hits": 1, "orders": 2}, transactions[0].Metrics) {
+		t.Errorf("expected provided transactions to be untouched but got %v", transactions[0].Metrics)
+	}
+
+	timestamped := []Transaction{
+		{Params: app, Metrics: Metrics{"hits": 1}, Timestamp: 1205},
+		{Params: app, Metrics: Metrics{"hits": 2}, Timestamp: 1259},
+		{Params: app, Metrics: Metrics{"hits": 3}, Timestamp: 1260},
+		{Params: app, Metrics: Metrics{"hits": 4}},
+	}
+
+	expect = []Transaction{
+		{Params: app, Metrics: Metrics{"hits": 3}, Timestamp: 1200},
+		{Params: app, Metrics: Metrics{"hits": 3}, Timestamp: 1260},
+		{Params: app, Metrics: Metrics{"hits": 4}},
+	}
+
+	got = CollapseTransactionsWithin(timestamped, time.Minute)
+	if !reflect.DeepEqual(expect, got) {
+		t.Errorf("unexpected result, wanted %v but got %v", expect, got)
+	}
+
+	if got := CollapseTransactions(timestamped); len(got) != len(timestamped) {
+		t.Errorf("expected transactions with distinct timestamps to remain separate but got %v", got)
+	}
+
+	logged := []Transaction{
+		{Params: app, Metrics: Metrics{"hits": 1}, Log: Log{Request: "GET /", Code: 200}},
+		{Params: app, Metrics: Metrics{"hits": 1}, Log: Log{Request: "GET /", Code: 500}},
+	}
+	if got := CollapseTransactions(logged); len(got) != len(logged) {
+		t.Errorf("expected transactions with distinct logs to remain separate but got %v", got)
+	}
+}
+
 func TestMetrics_Add(t *testing.T) {
 	m := make(Metrics)
 	current, err := m.Add("test", 1)
@@ -79,6 +332,57 @@ func TestMetrics_Set(t *testing.T) {
 	}
 }
 
+func TestMetrics_SetRaw(t *testing.T) {
+	m := Metrics{}
+	if err := m.SetRaw("hits", -5); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	if m["hits"] != -5 {
+		t.Errorf("expected negative value to be set but got %d", m["hits"])
+	}
+
+	if err := m.SetRaw("invalid name", -1); err == nil {
+		t.Error("expected error for invalid metric name")
+	}
+	if _, ok := m["invalid name"]; ok {
+		t.Error("expected invalid metric not to be set")
+	}
+}
+
+func TestMetrics_ValidateNames(t *testing.T) {
+	m := make(Metrics)
+	for _, name := range []string{"", "usage[hits]", "a]", "hits&other", "two words", "tab\t"} {
+		if err := m.Set(name, 1); err == nil {
+			t.Errorf("expected error when setting metric %q", name)
+		}
+
+		if _, err := m.Add(name, 1); err == nil {
+			t.Errorf("expected error when adding metric %q", name)
+		}
+	}
+
+	if len(m) != 0 {
+		t.Errorf("expected invalid metrics to be discarded but got %v", m)
+	}
+
+	if err := (Metrics{"hits": 1, "my_metric-2.0": 1}).ValidateNames(); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	if err := (Metrics{"hits": 1, "my.metric": 1, "my-metric": 1, "métrica_日本": 1}).ValidateNames(); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	if err := (Metrics{"hits": 1, "bad name": 1}).ValidateNames(); err == nil {
+		t.Error("expected error for invalid metric name")
+	}
+
+	// multi-byte characters should be reported in full
+	if err := (Metrics{"non\u00a0breaking": 1}).ValidateNames(); err == nil || !strings.Contains(err.Error(), `'\u00a0'`) {
+		t.Errorf("expected error to identify the invalid character but got %v", err)
+	}
+}
+
 func TestMetrics_Delete(t *testing.T) {
 	m := Metrics{"test": 1}
 	if len(m) != 1 {
@@ -90,6 +394,37 @@ func TestMetrics_Delete(t *testing.T) {
 	}
 }
 
+func TestMetrics_Subtract(t *testing.T) {
+	accumulated := Metrics{"hits": 10, "orders": 3, "searches": 2}
+	reported := Metrics{"hits": 4, "orders": 5, "searches": 2, "downloads": 1}
+
+	remaining := accumulated.Subtract(reported)
+	expect := Metrics{"hits": 6}
+	if !reflect.DeepEqual(expect, remaining) {
+		t.Errorf("unexpected result, wanted %v but got %v", expect, remaining)
+	}
+
+	if !reflect.DeepEqual(Metrics{"hits": 10, "orders": 3, "searches": 2}, accumulated) {
+		t.Errorf("expected original metrics to be untouched but got %v", accumulated)
+	}
+
+	if remaining.IsEmpty() {
+		t.Error("expected remaining metrics not to be empty")
+	}
+
+	if !remaining.Subtract(Metrics{"hits": 100}).IsEmpty() {
+		t.Error("expected metrics to be empty once all usage has been subtracted")
+	}
+
+	if !(Metrics{"hits": 0}).IsEmpty() || !(Metrics{}).IsEmpty() || !Metrics(nil).IsEmpty() {
+		t.Error("expected metrics without non-zero values to be empty")
+	}
+
+	if !reflect.DeepEqual(Metrics{"hits": 1}, Metrics{"hits": 1}.Subtract(nil)) {
+		t.Error("expected subtracting nil metrics to return a copy")
+	}
+}
+
 func TestMetrics_DeepCopy(t *testing.T) {
 	original := Metrics{"hits": 1, "test": 2}
 	clone := original.DeepCopy()
@@ -108,6 +443,64 @@ func TestMetrics_DeepCopy(t *testing.T) {
 	}
 }
 
+func TestSafeMetrics_Add(t *testing.T) {
+	const workers = 10
+	const increments = 100
+
+	var sm SafeMetrics
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				if _, err := sm.Add("hits", 1); err != nil {
+					t.Error("unexpected error")
+				}
+				sm.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := sm.Snapshot()["hits"]; got != workers*increments {
+		t.Errorf("unexpected value, wanted %d but got %d", workers*increments, got)
+	}
+
+	if _, err := sm.Add("hits", -(workers*increments + 1)); err == nil {
+		t.Error("expected error but got none")
+	}
+}
+
+func TestSafeMetrics(t *testing.T) {
+	var sm SafeMetrics
+	if err := sm.Set("hits", 5); err != nil {
+		t.Error("unexpected error")
+	}
+
+	if err := sm.Set("hits", -1); err == nil {
+		t.Error("expected error but got none")
+	}
+
+	if err := sm.Set("other", 2); err != nil {
+		t.Error("unexpected error")
+	}
+
+	sm.Delete("other")
+
+	snapshot := sm.Snapshot()
+	if !reflect.DeepEqual(Metrics{"hits": 5}, snapshot) {
+		t.Errorf("unexpected snapshot %v", snapshot)
+	}
+
+	snapshot["hits"] = 10
+	clone := sm.DeepCopy()
+	clone.Set("hits", 20)
+	if sm.Snapshot()["hits"] != 5 {
+		t.Error("expected changes to snapshot and clone to not modify original")
+	}
+}
+
 func TestMetrics_AddHierarchyToMetrics(t *testing.T) {
 	inputs := []struct {
 		name      string
@@ -214,6 +607,55 @@ func TestMetrics_SubtractHierarchyFromMetrics(t *testing.T) {
 	}
 }
 
+func TestPeriod_JSON(t *testing.T) {
+	for _, period := range []Period{Second, Minute, Hour, Day, Week, Month, Year, Eternity} {
+		window := PeriodWindow{Period: period, Start: 960, End: 1020}
+
+		data, err := json.Marshal(window)
+		if err != nil {
+			t.Fatalf("unexpected error marshalling %s - %s", period, err.Error())
+		}
+
+		expect := fmt.Sprintf(`{"period":"%s","start":960,"end":1020}`, period)
+		if string(data) != expect {
+			t.Errorf("unexpected JSON, wanted %s but got %s", expect, data)
+		}
+
+		var got PeriodWindow
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("unexpected error unmarshalling %s - %s", data, err.Error())
+		}
+		if !reflect.DeepEqual(window, got) {
+			t.Errorf("unexpected round trip result, wanted %v but got %v", window, got)
+		}
+	}
+
+	// integers were encoded before Second was introduced, when Minute had the value 0
+	legacy := `{"period":0,"start":960,"end":1020}`
+	var window PeriodWindow
+	if err := json.Unmarshal([]byte(legacy), &window); err != nil {
+		t.Fatalf("unexpected error unmarshalling %s - %s", legacy, err.Error())
+	}
+	if window.Period != Minute {
+		t.Errorf("expected legacy period to decode to %s but got %s", Minute, window.Period)
+	}
+
+	var period Period
+	if err := json.Unmarshal([]byte("6"), &period); err != nil || period != Eternity {
+		t.Errorf("expected legacy eternity to decode to %s but got %v - %v", Eternity, period, err)
+	}
+
+	for _, invalid := range []string{`"fortnight"`, "7", "-1", "true"} {
+		if err := json.Unmarshal([]byte(invalid), &period); err == nil {
+			t.Errorf("expected error unmarshalling %s", invalid)
+		}
+	}
+
+	if _, err := json.Marshal(Period(8)); err == nil {
+		t.Error("expected error marshalling unknown period")
+	}
+}
+
 func TestPeriodWindow_IsEqual(t *testing.T) {
 	base := PeriodWindow{
 		Period: Minute,
@@ -275,6 +717,62 @@ func TestPeriodWindow_IsEqual(t *testing.T) {
 	}
 }
 
+func TestPeriodWindow_Contains(t *testing.T) {
+	window := PeriodWindow{Period: Minute, Start: 960, End: 1020}
+
+	input := []struct {
+		name   string
+		window PeriodWindow
+		at     time.Time
+		expect bool
+	}{
+		{
+			name:   "Test time before start",
+			window: window,
+			at:     time.Unix(959, 0),
+			expect: false,
+		},
+		{
+			name:   "Test start is inclusive",
+			window: window,
+			at:     time.Unix(960, 0),
+			expect: true,
+		},
+		{
+			name:   "Test end is exclusive",
+			window: window,
+			at:     time.Unix(1020, 0),
+			expect: false,
+		},
+		{
+			name:   "Test eternity always contains",
+			window: PeriodWindow{Period: Eternity},
+			at:     time.Unix(1020, 0),
+			expect: true,
+		},
+	}
+
+	for _, test := range input {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.window.Contains(test.at); got != test.expect {
+				t.Errorf("unexpected result, wanted %v but got %v", test.expect, got)
+			}
+		})
+	}
+}
+
+func TestPeriodWindow_Duration(t *testing.T) {
+	window := PeriodWindow{Period: Minute, Start: 960, End: 1020}
+	if window.Duration() != time.Minute {
+		t.Errorf("unexpected duration, wanted %v but got %v", time.Minute, window.Duration())
+	}
+
+	eternity := PeriodWindow{Period: Eternity}
+	if eternity.Duration() != 0 {
+		t.Errorf("expected zero duration for eternity but got %v", eternity.Duration())
+	}
+}
+
 func TestUsageReport_IsSame(t *testing.T) {
 	basePeriodWindow := PeriodWindow{
 		Period: Minute,
@@ -342,6 +840,52 @@ func TestUsageReport_IsSame(t *testing.T) {
 	}
 }
 
+func TestUsageReport_Remaining(t *testing.T) {
+	input := []struct {
+		name          string
+		report        UsageReport
+		expectRemain  int
+		expectPercent float64
+	}{
+		{
+			name:          "Test partially consumed quota",
+			report:        UsageReport{MaxValue: 4, CurrentValue: 1},
+			expectRemain:  3,
+			expectPercent: 25,
+		},
+		{
+			name:          "Test exceeded quota is clamped",
+			report:        UsageReport{MaxValue: 4, CurrentValue: 6},
+			expectRemain:  0,
+			expectPercent: 150,
+		},
+		{
+			name:          "Test negative max value is unlimited",
+			report:        UsageReport{MaxValue: -1, CurrentValue: 6},
+			expectRemain:  -1,
+			expectPercent: -1,
+		},
+		{
+			name:          "Test zero max value is unlimited",
+			report:        UsageReport{MaxValue: 0, CurrentValue: 6},
+			expectRemain:  -1,
+			expectPercent: -1,
+		},
+	}
+
+	for _, test := range input {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.report.Remaining(); got != test.expectRemain {
+				t.Errorf("unexpected remaining, wanted %d but got %d", test.expectRemain, got)
+			}
+
+			if got := test.report.PercentUsed(); got != test.expectPercent {
+				t.Errorf("unexpected percent used, wanted %v but got %v", test.expectPercent, got)
+			}
+		})
+	}
+}
+
 func TestUsageReports_OrderByAscendingGranularity(t *testing.T) {
 	input := UsageReports{
 		"hits_one": {
@@ -380,13 +924,22 @@ func TestUsageReports_OrderByAscendingGranularity(t *testing.T) {
 					Period: Hour,
 				},
 			},
+			{
+				PeriodWindow: PeriodWindow{
+					Period: Second,
+				},
+			},
 		},
 	}
 
 	input.OrderByAscendingGranularity()
 	sorted := input["hits_one"]
 
-	var expect = []string{"minute", "hour", "day", "week", "month", "year", "eternity"}
+	if len(sorted) != 8 {
+		t.Errorf("unexpected number of reports after sorting")
+	}
+
+	var expect = []string{"second", "minute", "hour", "day", "week", "month", "year", "eternity"}
 
 	for i, value := range sorted {
 		if value.PeriodWindow.Period.String() != expect[i] {
@@ -433,13 +986,18 @@ func TestUsageReports_OrderByDescendingGranularity(t *testing.T) {
 					Period: Hour,
 				},
 			},
+			{
+				PeriodWindow: PeriodWindow{
+					Period: Second,
+				},
+			},
 		},
 	}
 
 	input.OrderByDescendingGranularity()
 	sorted := input["hits_one"]
 
-	var expect = []string{"eternity", "year", "month", "week", "day", "hour", "minute"}
+	var expect = []string{"eternity", "year", "month", "week", "day", "hour", "minute", "second"}
 
 	for i, value := range sorted {
 		if value.PeriodWindow.Period.String() != expect[i] {
@@ -447,3 +1005,346 @@ func TestUsageReports_OrderByDescendingGranularity(t *testing.T) {
 		}
 	}
 }
+
+func TestUsageReports_SortedByGranularity(t *testing.T) {
+	original := UsageReports{
+		"hits": {
+			{PeriodWindow: PeriodWindow{Period: Day}},
+			{PeriodWindow: PeriodWindow{Period: Minute}},
+			{PeriodWindow: PeriodWindow{Period: Hour}},
+		},
+	}
+	expectOriginal := []Period{Day, Minute, Hour}
+
+	assertOrder := func(reports []UsageReport, expect []Period) {
+		t.Helper()
+		if len(reports) != len(expect) {
+			t.Fatalf("unexpected number of reports, wanted %d but got %d", len(expect), len(reports))
+		}
+		for i, report := range reports {
+			if report.PeriodWindow.Period != expect[i] {
+				t.Errorf("Expected %s but got %s", expect[i], report.PeriodWindow.Period)
+			}
+		}
+	}
+
+	ascending := original.SortedByAscendingGranularity()
+	assertOrder(ascending["hits"], []Period{Minute, Hour, Day})
+	assertOrder(original["hits"], expectOriginal)
+
+	descending := original.SortedByDescendingGranularity()
+	assertOrder(descending["hits"], []Period{Day, Hour, Minute})
+	assertOrder(original["hits"], expectOriginal)
+}
+
+func TestUsageReports_FilterByPeriod(t *testing.T) {
+	original := UsageReports{
+		"hits": {
+			{PeriodWindow: PeriodWindow{Period: Minute, Start: 960, End: 1020}, MaxValue: 10, CurrentValue: 1},
+			{PeriodWindow: PeriodWindow{Period: Day, Start: 0, End: 86400}, MaxValue: 100, CurrentValue: 5},
+			{PeriodWindow: PeriodWindow{Period: Month, Start: 0, End: 2592000}, MaxValue: 1000, CurrentValue: 5},
+		},
+		"orders": {
+			{PeriodWindow: PeriodWindow{Period: Hour, Start: 0, End: 3600}, MaxValue: 5, CurrentValue: 2},
+		},
+	}
+	expectOriginal := original.DeepCopy()
+
+	filtered := original.FilterByPeriod(Day)
+	expect := UsageReports{
+		"hits": {
+			{PeriodWindow: PeriodWindow{Period: Day, Start: 0, End: 86400}, MaxValue: 100, CurrentValue: 5},
+		},
+	}
+	if !reflect.DeepEqual(expect, filtered) {
+		t.Errorf("unexpected result, wanted %v but got %v", expect, filtered)
+	}
+
+	// modifying the result must not affect the original
+	filtered["hits"][0].CurrentValue = 50
+	if !reflect.DeepEqual(expectOriginal, original) {
+		t.Errorf("expected original to be untouched but got %v", original)
+	}
+
+	if filtered := original.FilterByPeriod(Eternity); len(filtered) != 0 {
+		t.Errorf("expected no reports but got %v", filtered)
+	}
+}
+
+func TestUsageReports_ShouldThrottle(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	input := []struct {
+		name    string
+		reports UsageReports
+		expect  bool
+	}{
+		{
+			name:    "Test unknown metric is not throttled",
+			reports: UsageReports{},
+			expect:  false,
+		},
+		{
+			name: "Test remaining quota is not throttled",
+			reports: UsageReports{
+				"hits": {
+					{PeriodWindow: PeriodWindow{Period: Minute, Start: 960, End: 1020}, MaxValue: 5, CurrentValue: 4},
+				},
+			},
+			expect: false,
+		},
+		{
+			name: "Test most restrictive window applies",
+			reports: UsageReports{
+				"hits": {
+					{PeriodWindow: PeriodWindow{Period: Minute, Start: 960, End: 1020}, MaxValue: 5, CurrentValue: 1},
+					{PeriodWindow: PeriodWindow{Period: Hour, Start: 0, End: 3600}, MaxValue: 10, CurrentValue: 10},
+				},
+			},
+			expect: true,
+		},
+		{
+			name: "Test elapsed window is ignored",
+			reports: UsageReports{
+				"hits": {
+					{PeriodWindow: PeriodWindow{Period: Minute, Start: 900, End: 960}, MaxValue: 5, CurrentValue: 5},
+				},
+			},
+			expect: false,
+		},
+		{
+			name: "Test eternity window never resets",
+			reports: UsageReports{
+				"hits": {
+					{PeriodWindow: PeriodWindow{Period: Eternity}, MaxValue: 5, CurrentValue: 5},
+				},
+			},
+			expect: true,
+		},
+		{
+			name: "Test disabled metric is throttled",
+			reports: UsageReports{
+				"hits": {
+					{PeriodWindow: PeriodWindow{Period: Eternity}, MaxValue: 0, CurrentValue: 10},
+				},
+			},
+			expect: true,
+		},
+		{
+			name: "Test disabled metric is throttled before any usage",
+			reports: UsageReports{
+				"hits": {
+					{PeriodWindow: PeriodWindow{Period: Minute, Start: 960, End: 1020}, MaxValue: 0, CurrentValue: 0},
+				},
+			},
+			expect: true,
+		},
+		{
+			name: "Test unlimited window is not throttled",
+			reports: UsageReports{
+				"hits": {
+					{PeriodWindow: PeriodWindow{Period: Minute, Start: 960, End: 1020}, MaxValue: -1, CurrentValue: 10},
+					{PeriodWindow: PeriodWindow{Period: Eternity}, MaxValue: -1, CurrentValue: 10},
+				},
+			},
+			expect: false,
+		},
+	}
+
+	for _, test := range input {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.reports.ShouldThrottle("hits", now); got != test.expect {
+				t.Errorf("unexpected result, wanted %v but got %v", test.expect, got)
+			}
+		})
+	}
+}
+
+func TestUsageReports_MostConstrained(t *testing.T) {
+	minute := UsageReport{PeriodWindow: PeriodWindow{Period: Minute, Start: 960, End: 1020}, MaxValue: 10, CurrentValue: 5}
+	hour := UsageReport{PeriodWindow: PeriodWindow{Period: Hour, Start: 0, End: 3600}, MaxValue: 100, CurrentValue: 80}
+	day := UsageReport{PeriodWindow: PeriodWindow{Period: Day, Start: 0, End: 86400}, MaxValue: 1000, CurrentValue: 800}
+	eternity := UsageReport{PeriodWindow: PeriodWindow{Period: Eternity}, MaxValue: 10, CurrentValue: 8}
+	unlimited := UsageReport{PeriodWindow: PeriodWindow{Period: Month, Start: 0, End: 2592000}, MaxValue: 0, CurrentValue: 50}
+
+	input := []struct {
+		name        string
+		reports     UsageReports
+		expect      UsageReport
+		expectFound bool
+	}{
+		{
+			name:    "Test unknown metric",
+			reports: UsageReports{"hits": {minute}},
+		},
+		{
+			name:    "Test only unlimited windows",
+			reports: UsageReports{"other": {unlimited}},
+		},
+		{
+			name:        "Test highest percentage used is returned",
+			reports:     UsageReports{"other": {minute, hour, unlimited}},
+			expect:      hour,
+			expectFound: true,
+		},
+		{
+			name:        "Test window which resets last is returned on a tie",
+			reports:     UsageReports{"other": {day, hour, minute}},
+			expect:      day,
+			expectFound: true,
+		},
+		{
+			name:        "Test eternity resets last on a tie",
+			reports:     UsageReports{"other": {day, eternity}},
+			expect:      eternity,
+			expectFound: true,
+		},
+	}
+
+	for _, test := range input {
+		t.Run(test.name, func(t *testing.T) {
+			got, found := test.reports.MostConstrained("other")
+			if found != test.expectFound {
+				t.Errorf("unexpected found, wanted %v but got %v", test.expectFound, found)
+			}
+
+			if got != test.expect {
+				t.Errorf("unexpected usage report, wanted %v but got %v", test.expect, got)
+			}
+		})
+	}
+}
+
+func TestUsageReport_IsCurrent(t *testing.T) {
+	minute := UsageReport{PeriodWindow: PeriodWindow{Period: Minute, Start: 960, End: 1020}, MaxValue: 5}
+	eternity := UsageReport{PeriodWindow: PeriodWindow{Period: Eternity}, MaxValue: 5}
+
+	input := []struct {
+		name   string
+		report UsageReport
+		now    time.Time
+		expect bool
+	}{
+		{name: "Test before start", report: minute, now: time.Unix(959, 0)},
+		{name: "Test exactly start", report: minute, now: time.Unix(960, 0), expect: true},
+		{name: "Test within window", report: minute, now: time.Unix(1000, 0), expect: true},
+		{name: "Test just before end", report: minute, now: time.Unix(1019, 999), expect: true},
+		{name: "Test exactly end", report: minute, now: time.Unix(1020, 0)},
+		{name: "Test eternity", report: eternity, now: time.Unix(1000, 0), expect: true},
+	}
+
+	for _, test := range input {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.report.IsCurrent(test.now); got != test.expect {
+				t.Errorf("unexpected IsCurrent, wanted %v but got %v", test.expect, got)
+			}
+		})
+	}
+}
+
+func TestUsageReport_RetryAfter(t *testing.T) {
+	minute := UsageReport{PeriodWindow: PeriodWindow{Period: Minute, Start: 960, End: 1020}, MaxValue: 5, CurrentValue: 5}
+
+	if got := minute.RetryAfter(time.Unix(1000, 0)); got != 20*time.Second {
+		t.Errorf("unexpected retry after, wanted %v but got %v", 20*time.Second, got)
+	}
+
+	if got := minute.RetryAfter(time.Unix(1000, 500*int64(time.Millisecond))); got != 19500*time.Millisecond {
+		t.Errorf("unexpected retry after, wanted %v but got %v", 19500*time.Millisecond, got)
+	}
+
+	if got := minute.RetryAfter(time.Unix(1030, 0)); got != 0 {
+		t.Errorf("expected zero retry after for elapsed window but got %v", got)
+	}
+
+	eternity := UsageReport{PeriodWindow: PeriodWindow{Period: Eternity}, MaxValue: 5, CurrentValue: 5}
+	if got := eternity.RetryAfter(time.Unix(1000, 0)); got != 0 {
+		t.Errorf("expected zero retry after for eternity but got %v", got)
+	}
+}
+
+func TestUsageReports_NextResetAt(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	input := []struct {
+		name    string
+		reports UsageReports
+		expect  time.Time
+	}{
+		{
+			name: "Test zero time when not exhausted",
+			reports: UsageReports{
+				"hits": {
+					{PeriodWindow: PeriodWindow{Period: Minute, Start: 960, End: 1020}, MaxValue: 5, CurrentValue: 4},
+				},
+			},
+			expect: time.Time{},
+		},
+		{
+			name: "Test most restrictive exhausted window is returned",
+			reports: UsageReports{
+				"hits": {
+					{PeriodWindow: PeriodWindow{Period: Minute, Start: 960, End: 1020}, MaxValue: 5, CurrentValue: 5},
+					{PeriodWindow: PeriodWindow{Period: Hour, Start: 0, End: 3600}, MaxValue: 10, CurrentValue: 10},
+					{PeriodWindow: PeriodWindow{Period: Day, Start: 0, End: 86400}, MaxValue: 100, CurrentValue: 10},
+				},
+			},
+			expect: time.Unix(3600, 0),
+		},
+		{
+			name: "Test zero time when only unlimited windows are present",
+			reports: UsageReports{
+				"hits": {
+					{PeriodWindow: PeriodWindow{Period: Minute, Start: 960, End: 1020}, MaxValue: -1, CurrentValue: 10},
+				},
+			},
+			expect: time.Time{},
+		},
+		{
+			name: "Test disabled metric resets with its window",
+			reports: UsageReports{
+				"hits": {
+					{PeriodWindow: PeriodWindow{Period: Minute, Start: 960, End: 1020}, MaxValue: 0, CurrentValue: 0},
+				},
+			},
+			expect: time.Unix(1020, 0),
+		},
+		{
+			name: "Test elapsed exhausted window is ignored",
+			reports: UsageReports{
+				"hits": {
+					{PeriodWindow: PeriodWindow{Period: Minute, Start: 900, End: 960}, MaxValue: 5, CurrentValue: 5},
+					{PeriodWindow: PeriodWindow{Period: Minute, Start: 960, End: 1020}, MaxValue: 5, CurrentValue: 5},
+				},
+			},
+			expect: time.Unix(1020, 0),
+		},
+		{
+			name: "Test zero time when eternity window is exhausted",
+			reports: UsageReports{
+				"hits": {
+					{PeriodWindow: PeriodWindow{Period: Minute, Start: 960, End: 1020}, MaxValue: 5, CurrentValue: 5},
+					{PeriodWindow: PeriodWindow{Period: Eternity}, MaxValue: 5, CurrentValue: 5},
+				},
+			},
+			expect: time.Time{},
+		},
+	}
+
+	for _, test := range input {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.reports.NextResetAtTime("hits", now); !got.Equal(test.expect) {
+				t.Errorf("unexpected result, wanted %v but got %v", test.expect, got)
+			}
+		})
+	}
+
+	// NextResetAt evaluates the windows at the current time
+	current := time.Now()
+	reports := UsageReports{
+		"hits": {{PeriodWindow: PeriodWindow{Period: Hour, Start: current.Unix() - 60, End: current.Unix() + 3600}, MaxValue: 5, CurrentValue: 5}},
+	}
+	if got := reports.NextResetAt("hits"); got.Unix() != current.Unix()+3600 {
+		t.Errorf("unexpected result, wanted %v but got %v", time.Unix(current.Unix()+3600, 0), got)
+	}
+}
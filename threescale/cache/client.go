@@ -0,0 +1,222 @@
+// Package cache provides an opt-in threescale.Client implementation which caches the result of
+// Authorize calls in memory, reducing the number of round trips made to backend for read-heavy workloads.
+//
+// Caching trades correctness for throughput. A cached result will not reflect usage reported by other
+// clients, or changes made to the application in 3scale, until it expires. Entries expire after the
+// configured TTL or when the soonest limiting window resets, whichever is first, however a cached
+// authorization may still be served after the application has exceeded its limits elsewhere.
+// Callers who require an accurate, up to date decision on every call should not use this package.
+package cache
+
+import (
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/3scale/3scale-go-client/threescale"
+)
+
+// ensure CachingClient satisfies the threescale.Client interface
+var _ threescale.Client = (*CachingClient)(nil)
+
+// CachingClient wraps a threescale.Client, caching the results of Authorize calls keyed by the
+// service, credentials, extensions and metrics of the request. All other calls are passed through
+// to the underlying client. CachingClient is safe for concurrent use.
+type CachingClient struct {
+	client threescale.Client
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu        sync.RWMutex
+	entries   map[string]entry
+	lastSweep time.Time
+
+	hits   uint64
+	misses uint64
+}
+
+type entry struct {
+	result    threescale.AuthorizeResult
+	expiresAt time.Time
+}
+
+// NewCachingClient returns a CachingClient which wraps the provided client.
+// The ttl is the maximum amount of time a result will be cached for.
+func NewCachingClient(client threescale.Client, ttl time.Duration) *CachingClient {
+	return &CachingClient{
+		client:  client,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]entry),
+	}
+}
+
+// Authorize returns a cached result for the request if one exists and has not expired.
+// Otherwise the call is delegated to the underlying client and a successful result is cached.
+func (c *CachingClient) Authorize(request threescale.Request) (*threescale.AuthorizeResult, error) {
+	key := cacheKey(request)
+	now := c.now()
+
+	c.mu.RLock()
+	cached, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if ok && now.Before(cached.expiresAt) {
+		atomic.AddUint64(&c.hits, 1)
+		result := copyResult(cached.result)
+		return &result, nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+
+	result, err := c.client.Authorize(request)
+	if err != nil {
+		return result, err
+	}
+
+	c.mu.Lock()
+	c.sweep(now)
+	if ttl := c.ttlFor(result, now); ttl > 0 {
+		c.entries[key] = entry{
+			result:    copyResult(*result),
+			expiresAt: now.Add(ttl),
+		}
+	} else {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+
+	return result, nil
+}
+
+// AuthRep is passed through to the underlying client and is never cached
+func (c *CachingClient) AuthRep(request threescale.Request) (*threescale.AuthorizeResult, error) {
+	return c.client.AuthRep(request)
+}
+
+// OauthAuthorize is passed through to the underlying client and is never cached
+func (c *CachingClient) OauthAuthorize(request threescale.Request) (*threescale.AuthorizeResult, error) {
+	return c.client.OauthAuthorize(request)
+}
+
+// OauthAuthRep is passed through to the underlying client and is never cached
+func (c *CachingClient) OauthAuthRep(request threescale.Request) (*threescale.AuthorizeResult, error) {
+	return c.client.OauthAuthRep(request)
+}
+
+// Report is passed through to the underlying client and is never cached
+func (c *CachingClient) Report(request threescale.Request) (*threescale.ReportResult, error) {
+	return c.client.Report(request)
+}
+
+// GetPeer returns the hostname of the backend the underlying client is connected to
+func (c *CachingClient) GetPeer() string {
+	return c.client.GetPeer()
+}
+
+// Hits returns the number of Authorize calls served from the cache
+func (c *CachingClient) Hits() uint64 {
+	return atomic.LoadUint64(&c.hits)
+}
+
+// Misses returns the number of Authorize calls which were delegated to the underlying client
+func (c *CachingClient) Misses() uint64 {
+	return atomic.LoadUint64(&c.misses)
+}
+
+// Invalidate removes any cached result for the provided request
+func (c *CachingClient) Invalidate(request threescale.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, cacheKey(request))
+}
+
+// Purge removes all cached results
+func (c *CachingClient) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]entry)
+}
+
+// sweep removes expired entries so that results for requests which are not repeated do not accumulate.
+// Since no entry outlives the configured ttl, a sweep is only required once per ttl. Must be called with mu held.
+func (c *CachingClient) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.ttl {
+		return
+	}
+
+	for key, cached := range c.entries {
+		if !now.Before(cached.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	c.lastSweep = now
+}
+
+// copyResult returns a copy of result which shares no maps or pointers with the original, such that
+// neither the caller nor the cache can modify the result held by the other
+func copyResult(result threescale.AuthorizeResult) threescale.AuthorizeResult {
+	result.UsageReports = result.UsageReports.DeepCopy()
+	if result.Hierarchy != nil {
+		result.Hierarchy = result.Hierarchy.DeepCopy()
+	}
+	if result.RateLimits != nil {
+		rateLimits := *result.RateLimits
+		result.RateLimits = &rateLimits
+	}
+	return result
+}
+
+// ttlFor returns the duration for which the result can be cached. This is the configured ttl, reduced
+// to the time remaining until the soonest reset reported by the rate limiting extension or usage reports.
+func (c *CachingClient) ttlFor(result *threescale.AuthorizeResult, now time.Time) time.Duration {
+	ttl := c.ttl
+
+	if result.RateLimits != nil && result.RateLimits.LimitReset >= 0 {
+		if reset := time.Duration(result.RateLimits.LimitReset) * time.Second; reset < ttl {
+			ttl = reset
+		}
+	}
+
+	for _, reports := range result.UsageReports {
+		for _, report := range reports {
+			if report.IsForEternity() {
+				continue
+			}
+
+			if reset := time.Unix(report.PeriodWindow.End, 0).Sub(now); reset < ttl {
+				ttl = reset
+			}
+		}
+	}
+
+	return ttl
+}
+
+// cacheKey builds a key which uniquely identifies the inputs to an Authorize call
+func cacheKey(request threescale.Request) string {
+	values := url.Values{}
+	values.Set("service", string(request.Service))
+	values.Set(string(request.Auth.Type), request.Auth.Value)
+
+	for k, v := range request.Extensions {
+		values.Set("extensions["+k+"]", v)
+	}
+
+	if len(request.Transactions) > 0 {
+		transaction := request.Transactions[0]
+		values.Set("app_id", transaction.Params.AppID)
+		values.Set("app_key", transaction.Params.AppKey)
+		values.Set("referrer", transaction.Params.Referrer)
+		values.Set("user_id", transaction.Params.UserID)
+		values.Set("user_key", transaction.Params.UserKey)
+
+		for name, value := range transaction.Metrics {
+			values.Set("usage["+name+"]", strconv.Itoa(value))
+		}
+	}
+
+	// Encode sorts by key, producing a deterministic result
+	return values.Encode()
+}
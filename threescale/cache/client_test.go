@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-go-client/threescale"
+	"github.com/3scale/3scale-go-client/threescale/api"
+	"github.com/3scale/3scale-go-client/threescale/mock"
+)
+
+func TestCachingClient_Authorize(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	request := threescale.Request{
+		Auth:    api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service: "svc",
+		Transactions: []api.Transaction{
+			{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"hits": 1}},
+		},
+	}
+
+	m := &mock.MockClient{
+		AuthorizeFunc: func(request threescale.Request) (*threescale.AuthorizeResult, error) {
+			return &threescale.AuthorizeResult{
+				Authorized: true,
+				UsageReports: api.UsageReports{
+					"hits": {{PeriodWindow: api.PeriodWindow{Period: api.Minute, Start: 960, End: 1020}, MaxValue: 5}},
+				},
+			}, nil
+		},
+	}
+
+	c := NewCachingClient(m, time.Hour)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		result, err := c.Authorize(request)
+		if err != nil || !result.Authorized {
+			t.Fatalf("unexpected result %v - %v", result, err)
+		}
+	}
+
+	if len(m.Calls()) != 1 || c.Hits() != 2 || c.Misses() != 1 {
+		t.Errorf("expected a single call to backend, got %d calls, %d hits, %d misses",
+			len(m.Calls()), c.Hits(), c.Misses())
+	}
+
+	// a request for different metrics should not be served from the cache
+	other := request
+	other.Transactions = []api.Transaction{{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"hits": 2}}}
+	c.Authorize(other)
+	if len(m.Calls()) != 2 {
+		t.Error("expected request with different metrics to be delegated")
+	}
+
+	// ttl is bounded by the end of the minute window
+	now = time.Unix(1020, 0)
+	c.Authorize(request)
+	if len(m.Calls()) != 3 {
+		t.Error("expected cached result to have expired at window reset")
+	}
+
+	c.Invalidate(request)
+	c.Authorize(request)
+	if len(m.Calls()) != 4 {
+		t.Error("expected invalidated result to be delegated")
+	}
+
+	c.Purge()
+	c.Authorize(request)
+	if len(m.Calls()) != 5 {
+		t.Error("expected purged result to be delegated")
+	}
+
+	c.Report(request)
+	if len(m.Calls()) != 6 || m.Calls()[5].Method != mock.MethodReport {
+		t.Error("expected report to be passed through")
+	}
+}
+
+func TestCachingClient_RateLimitsBoundTTL(t *testing.T) {
+	now := time.Unix(1000, 0)
+	request := threescale.Request{Service: "svc"}
+
+	m := &mock.MockClient{
+		AuthorizeFunc: func(request threescale.Request) (*threescale.AuthorizeResult, error) {
+			return &threescale.AuthorizeResult{
+				Authorized:          true,
+				AuthorizeExtensions: threescale.AuthorizeExtensions{RateLimits: &api.RateLimits{LimitReset: 10}},
+			}, nil
+		},
+	}
+
+	c := NewCachingClient(m, time.Hour)
+	c.now = func() time.Time { return now }
+
+	c.Authorize(request)
+	now = now.Add(9 * time.Second)
+	c.Authorize(request)
+	if c.Hits() != 1 {
+		t.Errorf("expected cache hit before limit reset")
+	}
+
+	now = now.Add(time.Second)
+	c.Authorize(request)
+	if c.Misses() != 2 {
+		t.Errorf("expected cache miss after limit reset")
+	}
+}
+
+func TestCachingClient_SweepsExpiredEntries(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	m := &mock.MockClient{
+		AuthorizeFunc: func(request threescale.Request) (*threescale.AuthorizeResult, error) {
+			return &threescale.AuthorizeResult{Authorized: true}, nil
+		},
+	}
+
+	c := NewCachingClient(m, time.Minute)
+	c.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c"} {
+		c.Authorize(threescale.Request{Service: "svc", Transactions: []api.Transaction{{Params: api.Params{UserKey: key}}}})
+	}
+	if len(c.entries) != 3 {
+		t.Fatalf("expected 3 cached entries but got %d", len(c.entries))
+	}
+
+	// requests for a, b and c are never repeated, so their entries are only removed by a sweep
+	now = now.Add(time.Minute)
+	c.Authorize(threescale.Request{Service: "svc", Transactions: []api.Transaction{{Params: api.Params{UserKey: "d"}}}})
+	if len(c.entries) != 1 {
+		t.Errorf("expected expired entries to be swept on write, got %d entries", len(c.entries))
+	}
+}
+
+func TestCachingClient_CopiesResults(t *testing.T) {
+	request := threescale.Request{Service: "svc"}
+
+	m := &mock.MockClient{
+		AuthorizeFunc: func(request threescale.Request) (*threescale.AuthorizeResult, error) {
+			return &threescale.AuthorizeResult{
+				Authorized: true,
+				UsageReports: api.UsageReports{
+					"hits": {{PeriodWindow: api.PeriodWindow{Period: api.Eternity}, MaxValue: 5}},
+				},
+				AuthorizeExtensions: threescale.AuthorizeExtensions{
+					Hierarchy:  api.Hierarchy{"hits": {"method"}},
+					RateLimits: &api.RateLimits{LimitRemaining: 5, LimitReset: 100},
+				},
+			}, nil
+		},
+	}
+
+	c := NewCachingClient(m, time.Hour)
+
+	// modifying the result returned on a miss must not affect the cached result
+	result, _ := c.Authorize(request)
+	result.UsageReports["hits"][0].CurrentValue = 5
+	result.UsageReports["other"] = nil
+	result.Hierarchy["hits"][0] = "modified"
+	result.RateLimits.LimitRemaining = 0
+
+	// nor must modifying the result returned on a hit
+	for i := 0; i < 2; i++ {
+		cached, _ := c.Authorize(request)
+		if cached.UsageReports["hits"][0].CurrentValue != 0 || len(cached.UsageReports) != 1 {
+			t.Errorf("expected cached usage reports to be unmodified, got %v", cached.UsageReports)
+		}
+		if cached.Hierarchy["hits"][0] != "method" {
+			t.Errorf("expected cached hierarchy to be unmodified, got %v", cached.Hierarchy)
+		}
+		if cached.RateLimits.LimitRemaining != 5 {
+			t.Errorf("expected cached rate limits to be unmodified, got %v", cached.RateLimits)
+		}
+
+		cached.UsageReports["hits"][0].CurrentValue = 5
+		cached.Hierarchy["hits"][0] = "modified"
+		cached.RateLimits.LimitRemaining = 0
+	}
+}
@@ -1,6 +1,8 @@
 package threescale
 
 import (
+	"time"
+
 	"github.com/3scale/3scale-go-client/threescale/api"
 )
 
@@ -49,6 +51,12 @@ type AuthorizeResult struct {
 	RejectionReason string
 	// RawResponse may be set by the underlying client implementation
 	RawResponse interface{}
+	// RequestURL is the URL of the request made to backend with any credentials redacted.
+	// May be set by the underlying client implementation
+	RequestURL string
+	// Latency is the round trip time of the request made to backend.
+	// May be set by the underlying client implementation
+	Latency time.Duration
 	AuthorizeExtensions
 }
 
@@ -58,11 +66,79 @@ type ReportResult struct {
 	Accepted bool
 	// ErrorCode as returned by backend - see https://github.com/3scale/apisonator/blob/v2.96.2/docs/rfcs/error_responses.md
 	ErrorCode string
+	// RejectionReason - human readable string explaining why the report has not been accepted
+	RejectionReason string
+	// RateLimits from rate limiting extension 'limit_headers' - will be nil if not leveraged or unsupported.
+	// Where a report is split into multiple requests, these are the limits returned for the last request made.
+	RateLimits *api.RateLimits
+	// PartialCount is the number of transactions which were accepted before a report, split into multiple requests,
+	// was interrupted by a failure, rejection or cancellation. Transactions are reported in order so callers can
+	// retry the remainder. Zero otherwise.
+	PartialCount int
+	// AcceptedIndexes are the indexes of the transactions of the request which were accepted by backend.
+	// Contains every index when the report has been accepted, and the first PartialCount indexes when interrupted.
+	// May be set by the underlying client implementation
+	AcceptedIndexes []int
 	// RawResponse may be set by the underlying client implementation
 	RawResponse interface{}
+	// RequestURL is the URL of the request made to backend with any credentials redacted.
+	// May be set by the underlying client implementation
+	RequestURL string
+	// Latency is the round trip time of the request made to backend.
+	// May be set by the underlying client implementation
+	Latency time.Duration
+}
+
+// BackendStatus is returned by a client when querying the status of 3scale backend
+type BackendStatus struct {
+	// Status reported by backend - "ok" when backend is healthy
+	Status string
+	// BackendVersion is the version of the 3scale backend
+	BackendVersion string
+}
+
+// EnforcementDecision summarises an authorization in the form required by a gateway to respond to the caller
+type EnforcementDecision struct {
+	// Allow states if the request should be allowed
+	Allow bool
+	// HTTPStatus to respond with - 200 when allowed, 429 when limits have been exceeded and 403 otherwise
+	HTTPStatus int
+	// RetryAfter is the time until the exhausted limits reset, suitable for use as the value of a 'Retry-After' header.
+	// Zero unless limits have been exceeded and the time at which they reset is known.
+	RetryAfter time.Duration
+	// Reason the request has been denied, as an error code where available, otherwise human readable. Empty if allowed.
+	Reason string
+}
+
+// FailurePolicy determines the authorization decision made when backend is unavailable - see Decide
+type FailurePolicy int
+
+const (
+	// FailClosed denies requests when backend is unavailable
+	FailClosed FailurePolicy = iota
+	// FailOpen allows requests when backend is unavailable
+	FailOpen
+)
+
+// Application identifies an application to 3scale, by either its user key (API key) or its application id and
+// optional application key, allowing applications to be modelled by callers independently of any single Transaction.
+// See ToTransaction.
+type Application struct {
+	// AppID identifies the application when using the Application Identifier and Key pairs authentication method
+	AppID string
+	// AppKey is an optional, secret key for the AppID
+	AppKey string
+	// UserKey identifies the application when using the API Key authentication method.
+	// Takes priority over AppID and AppKey where both are provided.
+	UserKey string
+	// Referrer is an optional value required only if referrer filtering is enabled for the application
+	Referrer string
+	// UserID is an optional value identifying an end user of the application
+	UserID string
 }
 
-// Request encapsulates the requirements for a successful api call to 3scale backend
+// Request encapsulates the requirements for a successful api call to 3scale backend.
+// Prefer NewRequest, which ensures the Request is valid at construction.
 type Request struct {
 	Auth       api.ClientAuth
 	Extensions api.Extensions
@@ -0,0 +1,59 @@
+package threescale
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by client implementations. Underlying causes are wrapped so these values
+// can be matched with errors.Is
+var (
+	// ErrHTTPBuild is returned when the request to backend could not be built
+	ErrHTTPBuild = errors.New("error building http transaction")
+	// ErrBackend5xx is returned when backend responds with a 5xx status code
+	ErrBackend5xx = errors.New("unable to process request")
+	// ErrDecode is returned when the response from backend could not be decoded
+	ErrDecode = errors.New("failed to decode response from backend")
+	// ErrValidation is returned when a request is invalid and has not been sent to backend
+	ErrValidation = errors.New("invalid request")
+	// ErrUnexpectedRedirect is returned when backend responds with a redirect which has not been followed,
+	// for example due to the CheckRedirect policy of the http.Client
+	ErrUnexpectedRedirect = errors.New("unexpected redirect from backend")
+)
+
+// TransportError is returned by client implementations when backend could not be reached, for example due to a
+// network failure or timeout. No decision has been made by backend, so callers may choose to fail open.
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying cause
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// BackendError is returned by client implementations when backend has been reached but failed to process
+// the request, responding with a 5xx status code. BackendError matches ErrBackend5xx with errors.Is
+type BackendError struct {
+	// StatusCode of the response from backend
+	StatusCode int
+	// Status of the response from backend, for example "503 Service Unavailable". May be empty.
+	Status string
+}
+
+func (e *BackendError) Error() string {
+	status := e.Status
+	if status == "" {
+		status = fmt.Sprintf("%d", e.StatusCode)
+	}
+	return fmt.Sprintf("%s - status: %s", ErrBackend5xx.Error(), status)
+}
+
+// Unwrap returns ErrBackend5xx
+func (e *BackendError) Unwrap() error {
+	return ErrBackend5xx
+}
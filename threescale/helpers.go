@@ -1,19 +1,289 @@
 package threescale
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/3scale/3scale-go-client/threescale/api"
 )
 
-const timeLayout = "2006-01-02 15:04:05 -0700"
+const (
+	timeLayout = "2006-01-02 15:04:05 -0700"
+
+	// limitsExceededCode is the error code returned by backend when an application has exceeded its limits
+	limitsExceededCode = "limits_exceeded"
+	// limitsExceededReason is the reason returned by backend in the response body when an application
+	// has exceeded its limits. The body does not include an error code.
+	limitsExceededReason = "usage limits are exceeded"
+)
+
+// NewRequest returns a Request for the service, authenticated with auth, containing the provided transactions.
+// Returns an error wrapping ErrValidation if the resulting Request is invalid, for example if no transactions
+// are provided. See Validate.
+func NewRequest(service api.Service, auth api.ClientAuth, transactions ...api.Transaction) (Request, error) {
+	r := Request{
+		Auth:         auth,
+		Service:      service,
+		Transactions: transactions,
+	}
+
+	if err := r.Validate(); err != nil {
+		return Request{}, err
+	}
+	return r, nil
+}
+
+// WithExtensions returns a copy of the Request with the provided Extensions set
+func (r Request) WithExtensions(extensions api.Extensions) Request {
+	r.Extensions = extensions
+	return r
+}
 
 // GetServiceID from Request
 func (r Request) GetServiceID() api.Service {
 	return r.Service
 }
 
+// Validate the Request prior to sending to 3scale backend. The Request must contain a Service,
+// the client Auth must have a known type and non-empty value and at least one transaction must be provided.
+// The Params and metric names of each transaction must be valid - see api.Params Validate and api.Metrics ValidateNames.
+// Returns an error wrapping ErrValidation if the Request is invalid.
+func (r Request) Validate() error {
+	if r.Service == "" {
+		return validationErr("service must not be empty")
+	}
+
+	if r.Auth.Type != api.ServiceToken && r.Auth.Type != api.ProviderKey {
+		return validationErr(fmt.Sprintf("unknown auth type '%s'", r.Auth.Type))
+	}
+
+	if r.Auth.Value == "" {
+		return validationErr("auth value must not be empty")
+	}
+
+	if len(r.Transactions) == 0 {
+		return validationErr("at least one transaction must be provided")
+	}
+
+	for i, transaction := range r.Transactions {
+		if err := transaction.Params.Validate(); err != nil {
+			return validationErr(fmt.Sprintf("invalid params for transaction %d - %s", i, err.Error()))
+		}
+
+		if err := transaction.Metrics.ValidateNames(); err != nil {
+			return validationErr(fmt.Sprintf("invalid metrics for transaction %d - %s", i, err.Error()))
+		}
+	}
+	return nil
+}
+
+func validationErr(reason string) error {
+	return fmt.Errorf("%w - %s", ErrValidation, reason)
+}
+
+// ToTransaction returns a Transaction for the Application with the provided metrics. As backend rejects
+// Params containing both a user key and an application id, the application id and key are omitted where
+// a UserKey has been provided.
+func (a Application) ToTransaction(metrics api.Metrics) api.Transaction {
+	params := api.Params{
+		Referrer: a.Referrer,
+		UserID:   a.UserID,
+	}
+
+	if a.UserKey != "" {
+		params.UserKey = a.UserKey
+	} else {
+		params.AppID = a.AppID
+		params.AppKey = a.AppKey
+	}
+
+	return api.Transaction{
+		Params:  params,
+		Metrics: metrics,
+	}
+}
+
 // FormatTimestamp from unix time to string formatting as understood by 3scale
 func FormatTimestamp(timestamp int64) string {
 	return time.Unix(timestamp, 0).Format(timeLayout)
 }
+
+// ApplyHierarchy uses the Hierarchy returned by backend to expand the usage of child metrics, m, into their parents.
+// This is useful when reporting with the flat_usage extension, where the client is responsible for computing parent totals.
+// Returns new Metrics, leaving m in its original state. Where no Hierarchy was returned, a copy of m is returned.
+func (r AuthorizeResult) ApplyHierarchy(m api.Metrics) api.Metrics {
+	return m.AddHierarchyToMetrics(r.Hierarchy)
+}
+
+// Decide reduces the outcome of an authorization to an allow (true) or deny (false) decision.
+// Where backend is unavailable, either because it could not be reached (TransportError) or it failed to process
+// the request (BackendError), the policy determines the decision. Any other error results in a deny.
+func Decide(result *AuthorizeResult, err error, policy FailurePolicy) bool {
+	if err != nil {
+		var transportErr *TransportError
+		var backendErr *BackendError
+		if errors.As(err, &transportErr) || errors.As(err, &backendErr) {
+			return policy == FailOpen
+		}
+		return false
+	}
+	return result != nil && result.Authorized
+}
+
+// IsDenied returns true if the request has not been authorized, for any reason
+func (r AuthorizeResult) IsDenied() bool {
+	return !r.Authorized
+}
+
+// IsRateLimited returns true if the request has not been authorized because the application has exceeded its limits.
+// Either the error code or the rejection reason are used to make the decision.
+func (r AuthorizeResult) IsRateLimited() bool {
+	return r.IsLimitExceeded()
+}
+
+// Enforcement reduces the result to the decision a gateway should enforce at the time provided.
+// Where limits have been exceeded, the time until they reset is taken from the 'limit_headers' extension when
+// available, otherwise from the exhausted usage reports.
+func (r AuthorizeResult) Enforcement(now time.Time) EnforcementDecision {
+	if r.Authorized {
+		return EnforcementDecision{Allow: true, HTTPStatus: http.StatusOK}
+	}
+
+	decision := EnforcementDecision{HTTPStatus: http.StatusForbidden, Reason: r.ErrorCode}
+	if decision.Reason == "" {
+		decision.Reason = r.RejectionReason
+	}
+
+	if r.IsLimitExceeded() {
+		decision.HTTPStatus = http.StatusTooManyRequests
+		decision.RetryAfter = r.retryAfter(now)
+	}
+	return decision
+}
+
+// retryAfter returns the time until the exhausted limits of the result reset, or zero if unknown
+func (r AuthorizeResult) retryAfter(now time.Time) time.Duration {
+	if r.RateLimits != nil && r.RateLimits.LimitReset > 0 {
+		return time.Duration(r.RateLimits.LimitReset) * time.Second
+	}
+
+	var retryAfter time.Duration
+	for metric := range r.UsageReports {
+		resetAt := r.UsageReports.NextResetAtTime(metric, now)
+		if resetAt.IsZero() {
+			continue
+		}
+
+		if d := resetAt.Sub(now); d > retryAfter {
+			retryAfter = d
+		}
+	}
+	return retryAfter
+}
+
+// IsLimitExceeded returns true if the request has not been authorized because the application has exceeded its limits.
+// Backend responds to such requests with a 409 status, which is not treated as an error by the client.
+func (r AuthorizeResult) IsLimitExceeded() bool {
+	if r.Authorized {
+		return false
+	}
+	return isLimitExceeded(r.ErrorCode, r.RejectionReason)
+}
+
+// IsLimitExceeded returns true if the report has not been accepted because the application has exceeded its limits.
+// Backend responds to such requests with a 409 status, which is not treated as an error by the client.
+func (r ReportResult) IsLimitExceeded() bool {
+	if r.Accepted {
+		return false
+	}
+	return isLimitExceeded(r.ErrorCode, r.RejectionReason)
+}
+
+func isLimitExceeded(errorCode string, rejectionReason string) bool {
+	return errorCode == limitsExceededCode || rejectionReason == limitsExceededReason
+}
+
+// HTTPStatus returns the http status code which corresponds to the result.
+// Returns 200 when authorized, otherwise the status code mapped from the ErrorCode, or 500 where it is unknown.
+// See StatusCode for the status of the underlying http response.
+func (r AuthorizeResult) HTTPStatus() int {
+	if r.Authorized {
+		return http.StatusOK
+	}
+	return errorCodeToHTTPStatus(r.ErrorCode)
+}
+
+// HTTPStatus returns the http status code which corresponds to the result.
+// Returns 202 when accepted, otherwise the status code mapped from the ErrorCode, or 500 where it is unknown.
+// See StatusCode for the status of the underlying http response.
+func (r ReportResult) HTTPStatus() int {
+	if r.Accepted {
+		return http.StatusAccepted
+	}
+	return errorCodeToHTTPStatus(r.ErrorCode)
+}
+
+// StatusCode returns the status code of the underlying http response, or 0 if RawResponse is not an *http.Response
+func (r AuthorizeResult) StatusCode() int {
+	return rawStatusCode(r.RawResponse)
+}
+
+// StatusCode returns the status code of the underlying http response, or 0 if RawResponse is not an *http.Response
+func (r ReportResult) StatusCode() int {
+	return rawStatusCode(r.RawResponse)
+}
+
+func rawStatusCode(rawResponse interface{}) int {
+	if resp, ok := rawResponse.(*http.Response); ok && resp != nil {
+		return resp.StatusCode
+	}
+	return 0
+}
+
+func errorCodeToHTTPStatus(errorCode string) int {
+	if status := CodeToStatusCode(errorCode); status != 0 {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// CodeToStatusCode transforms a client response code to http status code. Returns 0 for unknown codes.
+// See https://github.com/3scale/apisonator/blob/v2.96.2/docs/rfcs/error_responses.md
+func CodeToStatusCode(errorCode string) int {
+	return errorCodeStatus[errorCode]
+}
+
+var errorCodeStatus = map[string]int{
+	"access_token_storage_error":              http.StatusBadRequest,
+	"not_valid_data":                          http.StatusBadRequest,
+	"bad_request":                             http.StatusBadRequest,
+	"access_token_already_exists":             http.StatusBadRequest,
+	"content_type_invalid":                    http.StatusBadRequest,
+	"provider_key_invalid":                    http.StatusForbidden,
+	"provider_key_invalid_or_service_missing": http.StatusForbidden,
+	"user_requires_registration":              http.StatusForbidden,
+	"user_key_invalid":                        http.StatusForbidden,
+	"authentication_error":                    http.StatusForbidden,
+	"provider_key_or_service_token_required":  http.StatusForbidden,
+	"service_token_invalid":                   http.StatusForbidden,
+	"access_token_invalid":                    http.StatusNotFound,
+	"application_not_found":                   http.StatusNotFound,
+	"application_token_invalid":               http.StatusNotFound,
+	"service_id_invalid":                      http.StatusNotFound,
+	"metric_invalid":                          http.StatusNotFound,
+	"limits_exceeded":                         http.StatusConflict,
+	"oauth_not_enabled":                       http.StatusConflict,
+	"redirect_uri_invalid":                    http.StatusConflict,
+	"redirect_url_invalid":                    http.StatusConflict,
+	"application_not_active":                  http.StatusConflict,
+	"application_key_invalid":                 http.StatusConflict,
+	"referrer_not_allowed":                    http.StatusConflict,
+	"transaction_timestamp_not_within_range":  http.StatusConflict,
+	"application_has_inconsistent_data":       http.StatusUnprocessableEntity,
+	"referrer_filter_invalid":                 http.StatusUnprocessableEntity,
+	"required_params_missing":                 http.StatusUnprocessableEntity,
+	"usage_value_invalid":                     http.StatusUnprocessableEntity,
+	"service_id_missing":                      http.StatusUnprocessableEntity,
+}
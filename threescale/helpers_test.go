@@ -1,7 +1,12 @@
 package threescale
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/3scale/3scale-go-client/threescale/api"
 )
@@ -21,6 +26,72 @@ func TestRequest_GetServiceID(t *testing.T) {
 	}
 }
 
+func TestNewRequest(t *testing.T) {
+	auth := api.ClientAuth{Type: api.ServiceToken, Value: "st"}
+	transaction := api.Transaction{Params: api.Params{UserKey: "key"}}
+
+	r, err := NewRequest("svc", auth, transaction)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	r = r.WithExtensions(api.NewExtensions().WithHierarchy().Build())
+	expect := Request{
+		Auth:         auth,
+		Extensions:   api.Extensions{api.HierarchyExtension: "1"},
+		Service:      "svc",
+		Transactions: []api.Transaction{transaction},
+	}
+	if !reflect.DeepEqual(expect, r) {
+		t.Errorf("unexpected request, wanted %v but got %v", expect, r)
+	}
+
+	if _, err := NewRequest("svc", auth); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected validation error when no transactions are provided but got %v", err)
+	}
+}
+
+func TestApplication_ToTransaction(t *testing.T) {
+	metrics := api.Metrics{"hits": 1}
+
+	tests := []struct {
+		name   string
+		app    Application
+		expect api.Params
+	}{
+		{
+			name:   "Test user key",
+			app:    Application{UserKey: "key", UserID: "user"},
+			expect: api.Params{UserKey: "key", UserID: "user"},
+		},
+		{
+			name:   "Test app id and key",
+			app:    Application{AppID: "app", AppKey: "secret", Referrer: "example.com"},
+			expect: api.Params{AppID: "app", AppKey: "secret", Referrer: "example.com"},
+		},
+		{
+			name:   "Test user key takes priority over app id",
+			app:    Application{AppID: "app", AppKey: "secret", UserKey: "key", Referrer: "example.com", UserID: "user"},
+			expect: api.Params{UserKey: "key", Referrer: "example.com", UserID: "user"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transaction := test.app.ToTransaction(metrics)
+			if !reflect.DeepEqual(test.expect, transaction.Params) {
+				t.Errorf("unexpected params, wanted %v but got %v", test.expect, transaction.Params)
+			}
+			if !reflect.DeepEqual(metrics, transaction.Metrics) {
+				t.Errorf("unexpected metrics, wanted %v but got %v", metrics, transaction.Metrics)
+			}
+			if err := transaction.Params.Validate(); err != nil {
+				t.Errorf("expected valid params but got %s", err.Error())
+			}
+		})
+	}
+}
+
 func TestFormatTimestamp(t *testing.T) {
 	const expect = "2020-03-10 11:31:31 +0000"
 	timestamp := int64(1583839891)
@@ -30,3 +101,413 @@ func TestFormatTimestamp(t *testing.T) {
 		t.Errorf("failed to convert timestamp, wanted %s, but got %s", expect, got)
 	}
 }
+
+func TestRequest_Validate(t *testing.T) {
+	valid := Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}}},
+	}
+
+	inputs := []struct {
+		name      string
+		modify    func(r *Request)
+		expectErr bool
+	}{
+		{
+			name:   "Test valid request",
+			modify: func(r *Request) {},
+		},
+		{
+			name:      "Test empty service",
+			modify:    func(r *Request) { r.Service = "" },
+			expectErr: true,
+		},
+		{
+			name:      "Test unknown auth type",
+			modify:    func(r *Request) { r.Auth.Type = "unknown" },
+			expectErr: true,
+		},
+		{
+			name:      "Test empty auth value",
+			modify:    func(r *Request) { r.Auth.Value = "" },
+			expectErr: true,
+		},
+		{
+			name:      "Test no transactions",
+			modify:    func(r *Request) { r.Transactions = nil },
+			expectErr: true,
+		},
+		{
+			name: "Test invalid transaction params",
+			modify: func(r *Request) {
+				r.Transactions = []api.Transaction{{Params: api.Params{UserKey: "key", AppID: "id"}}}
+			},
+			expectErr: true,
+		},
+		{
+			name: "Test invalid metric name",
+			modify: func(r *Request) {
+				r.Transactions = []api.Transaction{{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"usage[hits]": 1}}}
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			r := valid
+			input.modify(&r)
+
+			err := r.Validate()
+			if !input.expectErr {
+				if err != nil {
+					t.Errorf("unexpected error - %s", err.Error())
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrValidation) {
+				t.Errorf("expected validation error but got %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthorizeResult_HTTPStatus(t *testing.T) {
+	inputs := []struct {
+		name   string
+		result AuthorizeResult
+		expect int
+	}{
+		{
+			name:   "Test authorized",
+			result: AuthorizeResult{Authorized: true},
+			expect: http.StatusOK,
+		},
+		{
+			name:   "Test known error code",
+			result: AuthorizeResult{ErrorCode: "limits_exceeded"},
+			expect: http.StatusConflict,
+		},
+		{
+			name:   "Test unknown error code ignores response status",
+			result: AuthorizeResult{ErrorCode: "unknown", RawResponse: &http.Response{StatusCode: http.StatusConflict}},
+			expect: http.StatusInternalServerError,
+		},
+		{
+			name:   "Test unknown error code",
+			result: AuthorizeResult{ErrorCode: "unknown"},
+			expect: http.StatusInternalServerError,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			if got := input.result.HTTPStatus(); got != input.expect {
+				t.Errorf("unexpected status, wanted %d but got %d", input.expect, got)
+			}
+		})
+	}
+}
+
+func TestReportResult_HTTPStatus(t *testing.T) {
+	inputs := []struct {
+		name   string
+		result ReportResult
+		expect int
+	}{
+		{
+			name:   "Test accepted",
+			result: ReportResult{Accepted: true},
+			expect: http.StatusAccepted,
+		},
+		{
+			name:   "Test known error code",
+			result: ReportResult{ErrorCode: "user_key_invalid"},
+			expect: http.StatusForbidden,
+		},
+		{
+			name:   "Test unknown error code ignores response status",
+			result: ReportResult{ErrorCode: "unknown", RawResponse: &http.Response{StatusCode: http.StatusForbidden}},
+			expect: http.StatusInternalServerError,
+		},
+		{
+			name:   "Test unknown error code",
+			result: ReportResult{ErrorCode: "unknown"},
+			expect: http.StatusInternalServerError,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			if got := input.result.HTTPStatus(); got != input.expect {
+				t.Errorf("unexpected status, wanted %d but got %d", input.expect, got)
+			}
+		})
+	}
+}
+
+func TestResult_StatusCode(t *testing.T) {
+	var nilResponse *http.Response
+	inputs := []struct {
+		name        string
+		rawResponse interface{}
+		expect      int
+	}{
+		{name: "Test http response", rawResponse: &http.Response{StatusCode: http.StatusConflict}, expect: http.StatusConflict},
+		{name: "Test nil raw response"},
+		{name: "Test nil http response", rawResponse: nilResponse},
+		{name: "Test other raw response", rawResponse: "unexpected"},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			if got := (AuthorizeResult{RawResponse: input.rawResponse}).StatusCode(); got != input.expect {
+				t.Errorf("unexpected authorization status, wanted %d but got %d", input.expect, got)
+			}
+
+			if got := (ReportResult{RawResponse: input.rawResponse}).StatusCode(); got != input.expect {
+				t.Errorf("unexpected report status, wanted %d but got %d", input.expect, got)
+			}
+		})
+	}
+}
+
+func TestAuthorizeResult_IsRateLimited(t *testing.T) {
+	input := []struct {
+		name              string
+		result            AuthorizeResult
+		expectDenied      bool
+		expectRateLimited bool
+	}{
+		{
+			name:   "Test authorized",
+			result: AuthorizeResult{Authorized: true},
+		},
+		{
+			name:              "Test limits exceeded error code",
+			result:            AuthorizeResult{ErrorCode: "limits_exceeded"},
+			expectDenied:      true,
+			expectRateLimited: true,
+		},
+		{
+			name:              "Test limits exceeded reason",
+			result:            AuthorizeResult{RejectionReason: "usage limits are exceeded"},
+			expectDenied:      true,
+			expectRateLimited: true,
+		},
+		{
+			name:         "Test invalid credentials",
+			result:       AuthorizeResult{ErrorCode: "user_key_invalid", RejectionReason: `user key "any" is invalid`},
+			expectDenied: true,
+		},
+	}
+
+	for _, test := range input {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.result.IsDenied(); got != test.expectDenied {
+				t.Errorf("unexpected IsDenied, wanted %v but got %v", test.expectDenied, got)
+			}
+
+			if got := test.result.IsRateLimited(); got != test.expectRateLimited {
+				t.Errorf("unexpected IsRateLimited, wanted %v but got %v", test.expectRateLimited, got)
+			}
+
+			if got := test.result.IsLimitExceeded(); got != test.expectRateLimited {
+				t.Errorf("unexpected IsLimitExceeded, wanted %v but got %v", test.expectRateLimited, got)
+			}
+		})
+	}
+}
+
+func TestReportResult_IsLimitExceeded(t *testing.T) {
+	input := []struct {
+		name   string
+		result ReportResult
+		expect bool
+	}{
+		{
+			name:   "Test accepted",
+			result: ReportResult{Accepted: true},
+		},
+		{
+			name:   "Test limits exceeded error code",
+			result: ReportResult{ErrorCode: "limits_exceeded"},
+			expect: true,
+		},
+		{
+			name:   "Test limits exceeded reason",
+			result: ReportResult{RejectionReason: "usage limits are exceeded"},
+			expect: true,
+		},
+		{
+			name:   "Test invalid credentials",
+			result: ReportResult{ErrorCode: "user_key_invalid", RejectionReason: `user key "any" is invalid`},
+		},
+	}
+
+	for _, test := range input {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.result.IsLimitExceeded(); got != test.expect {
+				t.Errorf("unexpected IsLimitExceeded, wanted %v but got %v", test.expect, got)
+			}
+		})
+	}
+}
+
+func TestAuthorizeResult_Enforcement(t *testing.T) {
+	now := time.Unix(1000, 0)
+	exhausted := api.UsageReports{
+		"hits": {
+			{PeriodWindow: api.PeriodWindow{Period: api.Minute, Start: 960, End: 1020}, MaxValue: 5, CurrentValue: 5},
+			{PeriodWindow: api.PeriodWindow{Period: api.Hour, Start: 0, End: 3600}, MaxValue: 100, CurrentValue: 6},
+		},
+	}
+
+	inputs := []struct {
+		name   string
+		result AuthorizeResult
+		expect EnforcementDecision
+	}{
+		{
+			name:   "Test authorized",
+			result: AuthorizeResult{Authorized: true, UsageReports: exhausted},
+			expect: EnforcementDecision{Allow: true, HTTPStatus: http.StatusOK},
+		},
+		{
+			name:   "Test invalid credentials",
+			result: AuthorizeResult{ErrorCode: "user_key_invalid", RejectionReason: `user key "any" is invalid`},
+			expect: EnforcementDecision{HTTPStatus: http.StatusForbidden, Reason: "user_key_invalid"},
+		},
+		{
+			name:   "Test reason used when no error code",
+			result: AuthorizeResult{RejectionReason: "application key is missing"},
+			expect: EnforcementDecision{HTTPStatus: http.StatusForbidden, Reason: "application key is missing"},
+		},
+		{
+			name: "Test limits exceeded uses limit headers",
+			result: AuthorizeResult{
+				ErrorCode:           "limits_exceeded",
+				UsageReports:        exhausted,
+				AuthorizeExtensions: AuthorizeExtensions{RateLimits: &api.RateLimits{LimitRemaining: 0, LimitReset: 45}},
+			},
+			expect: EnforcementDecision{HTTPStatus: http.StatusTooManyRequests, RetryAfter: 45 * time.Second, Reason: "limits_exceeded"},
+		},
+		{
+			name:   "Test limits exceeded falls back to usage reports",
+			result: AuthorizeResult{RejectionReason: "usage limits are exceeded", UsageReports: exhausted},
+			expect: EnforcementDecision{HTTPStatus: http.StatusTooManyRequests, RetryAfter: 20 * time.Second, Reason: "usage limits are exceeded"},
+		},
+		{
+			name:   "Test limits exceeded with unknown reset",
+			result: AuthorizeResult{ErrorCode: "limits_exceeded"},
+			expect: EnforcementDecision{HTTPStatus: http.StatusTooManyRequests, Reason: "limits_exceeded"},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			if got := input.result.Enforcement(now); got != input.expect {
+				t.Errorf("unexpected decision, wanted %+v but got %+v", input.expect, got)
+			}
+		})
+	}
+}
+
+func TestAuthorizeResult_ApplyHierarchy(t *testing.T) {
+	inputs := []struct {
+		name      string
+		original  api.Metrics
+		hierarchy api.Hierarchy
+		expect    api.Metrics
+	}{
+		{
+			name:     "Test no hierarchy returns a copy",
+			original: api.Metrics{"hits": 10, "test": 5},
+			expect:   api.Metrics{"hits": 10, "test": 5},
+		},
+		{
+			name:     "Test childless parent unaffected",
+			original: api.Metrics{"hits": 10, "orphan": 5},
+			hierarchy: api.Hierarchy{
+				"other": []string{"child_one", "child_two"},
+			},
+			expect: api.Metrics{"hits": 10, "orphan": 5},
+		},
+		{
+			name:     "Test child metrics reflected onto known parent",
+			original: api.Metrics{"hits": 10, "orphan": 5, "child_one": 3},
+			hierarchy: api.Hierarchy{
+				"hits": []string{"child_one", "child_two"},
+			},
+			expect: api.Metrics{"hits": 13, "orphan": 5, "child_one": 3},
+		},
+		{
+			name:     "Test child metrics reflected onto unknown parent",
+			original: api.Metrics{"child_one": 3},
+			hierarchy: api.Hierarchy{
+				"hits": []string{"child_one", "child_two"},
+			},
+			expect: api.Metrics{"hits": 3, "child_one": 3},
+		},
+	}
+
+	for _, test := range inputs {
+		t.Run(test.name, func(t *testing.T) {
+			result := AuthorizeResult{AuthorizeExtensions: AuthorizeExtensions{Hierarchy: test.hierarchy}}
+			got := result.ApplyHierarchy(test.original)
+			if !reflect.DeepEqual(got, test.expect) {
+				t.Errorf("unexpected metrics computed, expected %v, but got %v", test.expect, got)
+			}
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	inputs := []struct {
+		name       string
+		result     *AuthorizeResult
+		err        error
+		expectOpen bool
+		expectShut bool
+	}{
+		{
+			name:       "Test authorized",
+			result:     &AuthorizeResult{Authorized: true},
+			expectOpen: true,
+			expectShut: true,
+		},
+		{
+			name:   "Test denied",
+			result: &AuthorizeResult{ErrorCode: "limits_exceeded"},
+		},
+		{
+			name:       "Test transport error",
+			err:        fmt.Errorf("wrapped - %w", &TransportError{Err: errors.New("connection refused")}),
+			expectOpen: true,
+		},
+		{
+			name:       "Test backend error",
+			result:     &AuthorizeResult{},
+			err:        &BackendError{StatusCode: http.StatusServiceUnavailable},
+			expectOpen: true,
+		},
+		{
+			name: "Test validation error",
+			err:  validationErr("service must not be empty"),
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			if got := Decide(input.result, input.err, FailOpen); got != input.expectOpen {
+				t.Errorf("unexpected decision when failing open, wanted %v but got %v", input.expectOpen, got)
+			}
+
+			if got := Decide(input.result, input.err, FailClosed); got != input.expectShut {
+				t.Errorf("unexpected decision when failing closed, wanted %v but got %v", input.expectShut, got)
+			}
+		})
+	}
+}
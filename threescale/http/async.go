@@ -0,0 +1,96 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/3scale/3scale-go-client/threescale"
+	"github.com/3scale/3scale-go-client/threescale/api"
+)
+
+// defaultMaxAsyncReports is the maximum number of asynchronous reports in flight unless overridden
+const defaultMaxAsyncReports = 100
+
+// ErrAsyncReportLimit is returned by ReportAsync when the maximum number of asynchronous reports are in flight
+var ErrAsyncReportLimit = errors.New("maximum number of asynchronous reports in flight")
+
+// ErrClientClosed is returned by ReportAsync once Close has been called
+var ErrClientClosed = errors.New("client has been closed")
+
+// ReportAsync validates the request before reporting it in a separate goroutine, returning without waiting for the
+// round trip to 3scale backend. Any failure to report, or rejection of the report, is provided to the handler set
+// via WithAsyncErrorHandler, otherwise it is discarded. Note that a context provided via WithContext continues to
+// bound the report after ReportAsync has returned.
+// The number of reports in flight is bounded (see WithMaxAsyncReports), with ErrAsyncReportLimit returned, and the
+// report discarded, once the bound is reached. Close waits for reports in flight to complete, after which
+// ErrClientClosed is returned.
+// The request is copied before ReportAsync returns, so the caller is free to modify or reuse it.
+func (c *Client) ReportAsync(apiCall threescale.Request, options ...Option) error {
+	if err := apiCall.Validate(); err != nil {
+		return err
+	}
+
+	c.asyncOnce.Do(func() {
+		limit := c.options.maxAsyncReports
+		if limit <= 0 {
+			limit = defaultMaxAsyncReports
+		}
+		c.asyncReports = make(chan struct{}, limit)
+	})
+
+	// the report must be registered with asyncWG under the lock, so that it cannot race with Close waiting for it
+	c.asyncMutex.Lock()
+	if c.closed {
+		c.asyncMutex.Unlock()
+		return ErrClientClosed
+	}
+
+	select {
+	case c.asyncReports <- struct{}{}:
+	default:
+		c.asyncMutex.Unlock()
+		return ErrAsyncReportLimit
+	}
+	c.asyncWG.Add(1)
+	c.asyncMutex.Unlock()
+
+	apiCall = copyRequest(apiCall)
+	opts := newOptions(options...)
+	go func() {
+		defer func() {
+			<-c.asyncReports
+			c.asyncWG.Done()
+		}()
+
+		result, err := c.doReport(apiCall, opts)
+		if err == nil && !result.Accepted {
+			err = fmt.Errorf("report not accepted - %s", result.ErrorCode)
+		}
+
+		if err != nil && opts.asyncErrorHandler != nil {
+			opts.asyncErrorHandler(err)
+		}
+	}()
+	return nil
+}
+
+// copyRequest returns a copy of the request which shares no maps or slices with the original
+func copyRequest(apiCall threescale.Request) threescale.Request {
+	if apiCall.Extensions != nil {
+		extensions := make(api.Extensions, len(apiCall.Extensions))
+		for k, v := range apiCall.Extensions {
+			extensions[k] = v
+		}
+		apiCall.Extensions = extensions
+	}
+
+	transactions := make([]api.Transaction, len(apiCall.Transactions))
+	for i, transaction := range apiCall.Transactions {
+		if transaction.Metrics != nil {
+			transaction.Metrics = transaction.Metrics.DeepCopy()
+		}
+		transactions[i] = transaction
+	}
+	apiCall.Transactions = transactions
+	return apiCall
+}
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/3scale/3scale-go-client/fake"
+	"github.com/3scale/3scale-go-client/threescale"
+	"github.com/3scale/3scale-go-client/threescale/api"
+	"github.com/3scale/3scale-go-client/threescale/testutil"
+)
+
+func TestClient_ReportAsync(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	var requests int
+	httpClient := NewTestClient(func(req *http.Request) *http.Response {
+		<-release
+		mutex.Lock()
+		requests++
+		mutex.Unlock()
+		return testutil.NewResponse(http.StatusAccepted).Build()
+	})
+
+	c, err := NewClient(defaultBackendUrl, httpClient, WithMaxAsyncReports(2))
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	if err := c.ReportAsync(threescale.Request{}); !errors.Is(err, threescale.ErrValidation) {
+		t.Errorf("expected validation error but got %v", err)
+	}
+
+	var handled []error
+	handler := WithAsyncErrorHandler(func(err error) {
+		mutex.Lock()
+		handled = append(handled, err)
+		mutex.Unlock()
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := c.ReportAsync(apiCall, handler); err != nil {
+			t.Fatalf("unexpected error - %s", err.Error())
+		}
+	}
+
+	if err := c.ReportAsync(apiCall, handler); !errors.Is(err, ErrAsyncReportLimit) {
+		t.Errorf("expected ErrAsyncReportLimit but got %v", err)
+	}
+
+	close(release)
+	c.asyncWG.Wait()
+	equals(t, 2, requests)
+	equals(t, 0, len(handled))
+
+	// capacity is released once reports complete
+	if err := c.ReportAsync(apiCall, handler); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	if err := c.Close(); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+	equals(t, 3, requests)
+
+	if err := c.ReportAsync(apiCall, handler); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("expected ErrClientClosed but got %v", err)
+	}
+	equals(t, 3, requests)
+}
+
+func TestClient_ReportAsyncCopiesRequest(t *testing.T) {
+	release := make(chan struct{})
+	reported := make(chan url.Values, 1)
+	httpClient := NewTestClient(func(req *http.Request) *http.Response {
+		<-release
+		body, _ := ioutil.ReadAll(req.Body)
+		values, _ := url.ParseQuery(string(body))
+		reported <- values
+		return testutil.NewResponse(http.StatusAccepted).Build()
+	})
+	c := threeScaleTestClient(t, httpClient)
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Extensions:   api.Extensions{"no_body": "1"},
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	if err := c.ReportAsync(apiCall); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	// the caller reuses the request while the report is in flight
+	apiCall.Transactions[0].Params.UserKey = "other"
+	apiCall.Transactions[0].Metrics["hits"] = 100
+	apiCall.Extensions["no_body"] = "0"
+	close(release)
+
+	values := <-reported
+	equals(t, "key", values.Get("transactions[0][user_key]"))
+	equals(t, "1", values.Get("transactions[0][usage][hits]"))
+	c.Close()
+}
+
+func TestClient_ReportAsyncConcurrentClose(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	c := threeScaleTestClient(t, NewTestClient(testutil.RespondWith(testutil.NewResponse(http.StatusAccepted))))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.ReportAsync(apiCall); err != nil && !errors.Is(err, ErrClientClosed) && !errors.Is(err, ErrAsyncReportLimit) {
+				t.Errorf("unexpected error - %s", err.Error())
+			}
+		}()
+	}
+	c.Close()
+	wg.Wait()
+}
+
+func TestWithAsyncErrorHandler(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	errs := make(chan error, 2)
+	handler := WithAsyncErrorHandler(func(err error) {
+		errs <- err
+	})
+
+	rejecting := threeScaleTestClient(t, NewTestClient(testutil.RespondWith(
+		testutil.NewResponse(http.StatusForbidden).WithXML(fake.GenInvalidUserKey("key")),
+	)))
+	if err := rejecting.ReportAsync(apiCall, handler); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	if err := <-errs; !strings.Contains(err.Error(), "user_key_invalid") {
+		t.Errorf("expected rejection to be handled but got %v", err)
+	}
+
+	unreachable := threeScaleTestClient(t, &http.Client{Transport: failingTransport{}})
+	if err := unreachable.ReportAsync(apiCall, handler); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	var transportErr *threescale.TransportError
+	if err := <-errs; !errors.As(err, &transportErr) {
+		t.Errorf("expected transport error to be handled but got %v", err)
+	}
+}
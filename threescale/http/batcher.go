@@ -0,0 +1,171 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/3scale/3scale-go-client/threescale"
+	"github.com/3scale/3scale-go-client/threescale/api"
+)
+
+// ErrBatcherClosed is returned by Batcher.Add once the Batcher has been closed
+var ErrBatcherClosed = errors.New("batcher is closed")
+
+// FlushError is returned when a Batcher fails to report one or more groups of transactions. The Batcher does not
+// retry, so the transactions which were not reported are returned to the caller, who may report them again.
+type FlushError struct {
+	// Failed holds a Request, per group, for the transactions which were not reported
+	Failed []threescale.Request
+	// Errs holds the cause of the failure of the Request at the same index of Failed
+	Errs []error
+}
+
+func (e *FlushError) Error() string {
+	failed := make([]string, len(e.Failed))
+	for i, request := range e.Failed {
+		failed[i] = fmt.Sprintf("service %s - %s", request.Service, e.Errs[i].Error())
+	}
+	return fmt.Sprintf("failed to flush batched reports - %v", failed)
+}
+
+// Batcher accumulates transactions client side and reports them to 3scale on an interval, or when
+// the number of pending transactions reaches a threshold, reducing the number of round trips to backend.
+// Transactions are grouped by service and client authentication, with each group sent as a single report.
+// A Batcher is safe for concurrent use.
+type Batcher struct {
+	client  threescale.Client
+	maxSize int
+	onError func(error)
+
+	mutex   sync.Mutex
+	pending map[batchKey][]api.Transaction
+	size    int
+	closed  bool
+
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+type batchKey struct {
+	service api.Service
+	auth    api.ClientAuth
+}
+
+// NewBatcher returns a Batcher which reports via the provided client every flushInterval, or whenever maxSize
+// transactions are pending. A flushInterval less than or equal to 0 disables periodic flushing.
+// onError, if not nil, is called with any error encountered whilst flushing in the background. Such errors are a
+// *FlushError holding the transactions which were not reported, which are otherwise lost.
+// Close must be called to flush remaining transactions and release resources.
+func NewBatcher(client threescale.Client, flushInterval time.Duration, maxSize int, onError func(error)) *Batcher {
+	b := &Batcher{
+		client:  client,
+		maxSize: maxSize,
+		onError: onError,
+		pending: make(map[batchKey][]api.Transaction),
+		done:    make(chan struct{}),
+	}
+
+	if flushInterval > 0 {
+		b.wg.Add(1)
+		go b.run(flushInterval)
+	}
+	return b
+}
+
+// Add queues a transaction to be reported for the service with the provided auth.
+// If the number of pending transactions reaches the maximum size, pending transactions are flushed
+// before returning and any error encountered whilst flushing is returned.
+func (b *Batcher) Add(service api.Service, auth api.ClientAuth, transaction api.Transaction) error {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return ErrBatcherClosed
+	}
+
+	key := batchKey{service: service, auth: auth}
+	b.pending[key] = append(b.pending[key], transaction)
+	b.size++
+	shouldFlush := b.maxSize > 0 && b.size >= b.maxSize
+	b.mutex.Unlock()
+
+	if shouldFlush {
+		return b.Flush()
+	}
+	return nil
+}
+
+// Flush reports all pending transactions, which are removed from the Batcher whether or not they are reported.
+// All groups are reported even if some fail, returning a *FlushError holding the transactions which were not
+// reported. Where the client reports the number of transactions accepted before failing (see
+// threescale.ReportResult PartialCount), only the remainder are returned.
+func (b *Batcher) Flush() error {
+	b.mutex.Lock()
+	pending := b.pending
+	b.pending = make(map[batchKey][]api.Transaction)
+	b.size = 0
+	b.mutex.Unlock()
+
+	flushErr := &FlushError{}
+	for key, transactions := range pending {
+		request := threescale.Request{
+			Auth:         key.auth,
+			Service:      key.service,
+			Transactions: transactions,
+		}
+
+		result, err := b.client.Report(request)
+		if err == nil && !result.Accepted {
+			err = fmt.Errorf("report not accepted - %s", result.ErrorCode)
+		}
+
+		if err != nil {
+			if result != nil && result.PartialCount > 0 && result.PartialCount < len(transactions) {
+				request.Transactions = transactions[result.PartialCount:]
+			}
+			flushErr.Failed = append(flushErr.Failed, request)
+			flushErr.Errs = append(flushErr.Errs, err)
+		}
+	}
+
+	if len(flushErr.Failed) > 0 {
+		return flushErr
+	}
+	return nil
+}
+
+// Close stops periodic flushing and reports any pending transactions, returning a *FlushError holding any which
+// were not reported, as per Flush. Calls to Add after Close return ErrBatcherClosed. Close is safe to call more than once.
+func (b *Batcher) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		b.mutex.Lock()
+		b.closed = true
+		b.mutex.Unlock()
+
+		close(b.done)
+		b.wg.Wait()
+		err = b.Flush()
+	})
+	return err
+}
+
+func (b *Batcher) run(flushInterval time.Duration) {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.Flush(); err != nil && b.onError != nil {
+				b.onError(err)
+			}
+		case <-b.done:
+			return
+		}
+	}
+}
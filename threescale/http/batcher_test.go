@@ -0,0 +1,168 @@
+package http
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-go-client/threescale"
+	"github.com/3scale/3scale-go-client/threescale/api"
+	"github.com/3scale/3scale-go-client/threescale/mock"
+)
+
+func TestBatcher_MaxSize(t *testing.T) {
+	m := &mock.MockClient{
+		ReportFunc: func(request threescale.Request) (*threescale.ReportResult, error) {
+			return &threescale.ReportResult{Accepted: true}, nil
+		},
+	}
+
+	b := NewBatcher(m, 0, 3, nil)
+	auth := api.ClientAuth{Type: api.ServiceToken, Value: "st"}
+
+	for i := 0; i < 2; i++ {
+		if err := b.Add("svc", auth, api.Transaction{Params: api.Params{UserKey: "key"}}); err != nil {
+			t.Errorf("unexpected error - %s", err.Error())
+		}
+	}
+	equals(t, 0, len(m.Calls()))
+
+	if err := b.Add("svc", auth, api.Transaction{Params: api.Params{UserKey: "key"}}); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	calls := m.Calls()
+	equals(t, 1, len(calls))
+	equals(t, 3, len(calls[0].Request.Transactions))
+	equals(t, api.Service("svc"), calls[0].Request.Service)
+
+	if err := b.Close(); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+	equals(t, 1, len(m.Calls()))
+}
+
+func TestBatcher_Close(t *testing.T) {
+	m := &mock.MockClient{
+		ReportFunc: func(request threescale.Request) (*threescale.ReportResult, error) {
+			return &threescale.ReportResult{Accepted: true}, nil
+		},
+	}
+
+	b := NewBatcher(m, time.Hour, 100, nil)
+	authOne := api.ClientAuth{Type: api.ServiceToken, Value: "one"}
+	authTwo := api.ClientAuth{Type: api.ServiceToken, Value: "two"}
+
+	b.Add("svc-one", authOne, api.Transaction{Params: api.Params{UserKey: "a"}})
+	b.Add("svc-one", authOne, api.Transaction{Params: api.Params{UserKey: "b"}})
+	b.Add("svc-two", authTwo, api.Transaction{Params: api.Params{UserKey: "c"}})
+
+	if err := b.Close(); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	reported := make(map[api.Service]int)
+	for _, call := range m.Calls() {
+		reported[call.Request.Service] += len(call.Request.Transactions)
+	}
+	equals(t, map[api.Service]int{"svc-one": 2, "svc-two": 1}, reported)
+
+	if err := b.Add("svc-one", authOne, api.Transaction{}); !errors.Is(err, ErrBatcherClosed) {
+		t.Errorf("expected ErrBatcherClosed adding to a closed batcher but got %v", err)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Error("expected repeated close to be a no-op")
+	}
+}
+
+func TestBatcher_Interval(t *testing.T) {
+	var once sync.Once
+	flushed := make(chan struct{})
+	m := &mock.MockClient{
+		ReportFunc: func(request threescale.Request) (*threescale.ReportResult, error) {
+			once.Do(func() { close(flushed) })
+			return &threescale.ReportResult{Accepted: false, ErrorCode: "user_key_invalid"}, nil
+		},
+	}
+
+	errs := make(chan error, 1)
+	b := NewBatcher(m, time.Millisecond, 100, func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	defer b.Close()
+
+	b.Add("svc", api.ClientAuth{Type: api.ServiceToken, Value: "st"}, api.Transaction{Params: api.Params{UserKey: "a"}})
+
+	select {
+	case <-flushed:
+	case <-time.After(time.Second):
+		t.Fatal("expected pending transactions to have been flushed on interval")
+	}
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected error to have been passed to handler")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected rejected report to be passed to error handler")
+	}
+}
+
+func TestBatcher_FlushReturnsFailedTransactions(t *testing.T) {
+	m := &mock.MockClient{
+		ReportFunc: func(request threescale.Request) (*threescale.ReportResult, error) {
+			switch request.Service {
+			case "failing":
+				return nil, &threescale.TransportError{Err: errors.New("unreachable")}
+			case "rejected":
+				return &threescale.ReportResult{Accepted: false, ErrorCode: "user_key_invalid"}, nil
+			case "partial":
+				return &threescale.ReportResult{Accepted: false, PartialCount: 1}, errors.New("failed to report chunk 1 of 2")
+			}
+			return &threescale.ReportResult{Accepted: true}, nil
+		},
+	}
+
+	b := NewBatcher(m, 0, 100, nil)
+	auth := api.ClientAuth{Type: api.ServiceToken, Value: "st"}
+
+	b.Add("ok", auth, api.Transaction{Params: api.Params{UserKey: "a"}})
+	b.Add("failing", auth, api.Transaction{Params: api.Params{UserKey: "b"}})
+	b.Add("rejected", auth, api.Transaction{Params: api.Params{UserKey: "c"}})
+	b.Add("partial", auth, api.Transaction{Params: api.Params{UserKey: "d"}})
+	b.Add("partial", auth, api.Transaction{Params: api.Params{UserKey: "e"}})
+
+	err := b.Flush()
+	var flushErr *FlushError
+	if !errors.As(err, &flushErr) {
+		t.Fatalf("expected FlushError but got %v", err)
+	}
+	equals(t, len(flushErr.Failed), len(flushErr.Errs))
+
+	failed := make(map[api.Service][]string)
+	for i, request := range flushErr.Failed {
+		equals(t, auth, request.Auth)
+		for _, transaction := range request.Transactions {
+			failed[request.Service] = append(failed[request.Service], transaction.Params.UserKey)
+		}
+
+		var transportErr *threescale.TransportError
+		if request.Service == "failing" && !errors.As(flushErr.Errs[i], &transportErr) {
+			t.Errorf("expected cause to be preserved but got %v", flushErr.Errs[i])
+		}
+	}
+
+	// transactions accepted before a failure are not returned
+	equals(t, map[api.Service][]string{"failing": {"b"}, "rejected": {"c"}, "partial": {"e"}}, failed)
+
+	// failed transactions are not retained by the batcher
+	if err := b.Close(); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+}
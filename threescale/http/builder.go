@@ -1,11 +1,14 @@
 package http
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/3scale/3scale-go-client/threescale"
 
@@ -13,18 +16,56 @@ import (
 )
 
 type requestBuilder struct {
+	// onlyPositiveMetrics discards metrics with non-positive values when usage is being reported
+	onlyPositiveMetrics bool
+	// userAgent is set as the User-Agent header of the request
+	userAgent string
+	// clientInfo is set as the client info header of the request when not empty
+	clientInfo string
+	// includeZeroMetrics sends metrics with a zero value to backend rather than omitting them
+	includeZeroMetrics bool
+	// endpoints are the paths of the endpoints for each kind of request
+	endpoints EndpointConfig
+	// authorizeTimestamp sends the timestamp of the transaction on authorization calls
+	authorizeTimestamp bool
+	// responseFormat determines the Accept header of the request
+	responseFormat ResponseFormat
 }
 
-func (rb requestBuilder) build(in threescale.Request, baseURL string, kind kind) (*http.Request, error) {
-	req, err := rb.kindToHTTPRequest(baseURL, kind)
+func (rb requestBuilder) build(ctx context.Context, in threescale.Request, baseURL *url.URL, kind kind) (*http.Request, error) {
+	// guard against sending a malformed request to backend, which responds with a confusing error
+	if in.Service == "" {
+		return nil, fmt.Errorf("service id must not be empty")
+	}
+
+	values := rb.setValues(in, kind)
+
+	// report is a POST so its values are sent in the request body to avoid exceeding
+	// the URL length limits of the backend for large reports
+	var body io.Reader
+	if kind == report {
+		body = strings.NewReader(values.Encode())
+	}
+
+	req, err := rb.kindToHTTPRequest(ctx, baseURL, kind, body)
 	if err != nil {
 		return req, err
 	}
 
-	values := rb.setValues(in, kind)
+	req.Header.Set("Accept", rb.responseFormat.mediaType())
+	if rb.userAgent != "" {
+		req.Header.Set("User-Agent", rb.userAgent)
+	}
+
+	if rb.clientInfo != "" {
+		req.Header.Set(clientInfoHeaderKey, rb.clientInfo)
+	}
 
-	req.Header.Set("Accept", "application/xml")
-	req.URL.RawQuery = values.Encode()
+	if kind == report {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req.URL.RawQuery = values.Encode()
+	}
 
 	if in.Extensions != nil {
 		req.Header.Set(enableExtensions, rb.encodeExtensions(in.Extensions))
@@ -33,23 +74,59 @@ func (rb requestBuilder) build(in threescale.Request, baseURL string, kind kind)
 	return req, nil
 }
 
+// setValues builds the values for the request. Values are added in place to a single url.Values
+// to avoid allocating, and then merging, intermediate values on the hot path.
+// Metrics are formatted as 'usage[name]' keys, which are escaped when the values are encoded,
+// so any metric name which passes api.Metrics.ValidateNames is safe to use.
 func (rb requestBuilder) setValues(in threescale.Request, kind kind) url.Values {
-	values := rb.joinValues(make(url.Values), rb.serviceToValues(in.Service))
-	values = rb.joinValues(values, rb.authToValues(in.Auth))
+	values := make(url.Values)
+	values.Set(serviceIDKey, string(in.Service))
+	values.Set(string(in.Auth.Type), in.Auth.Value)
 
 	if kind == report {
 		for index, transaction := range in.Transactions {
-			values = rb.joinValues(values, rb.transactionToValues(index, transaction))
+			rb.addTransaction(values, index, transaction)
 		}
 	} else {
 		// the significance of the first entry here is important to call out, since
 		// since auth, authrep only handle a single transaction, any others will be discarded
-		values = rb.joinValues(values, rb.metricsToValues(in.Transactions[0].Metrics))
-		values = rb.joinValues(values, rb.paramsToValues(in.Transactions[0].Params))
+		transaction := in.Transactions[0]
+		reporting := kind == authRep || kind == oauthAuthRep
+		for metricName, incrementBy := range transaction.Metrics {
+			if rb.omitMetric(incrementBy) || (reporting && !rb.isReportable(incrementBy)) {
+				continue
+			}
+			values.Set("usage["+metricName+"]", strconv.Itoa(incrementBy))
+		}
+
+		rb.forEachParam(transaction.Params, func(key string, value string) {
+			values.Set(key, value)
+		})
+
+		// authrep records usage so honour the timestamp for backfilling delayed traffic,
+		// authorize only does so when explicitly requested as not all backends support it
+		if rb.sendTimestamp(kind) && transaction.Timestamp != 0 {
+			values.Set(timestampKey, threescale.FormatTimestamp(transaction.Timestamp))
+		}
+
+		if reporting {
+			rb.addLog(values, "", transaction.Log)
+		}
 	}
 	return values
 }
 
+func (rb requestBuilder) sendTimestamp(kind kind) bool {
+	switch kind {
+	case authRep, oauthAuthRep:
+		return true
+	case auth, oauthAuth:
+		return rb.authorizeTimestamp
+	default:
+		return false
+	}
+}
+
 func (rb requestBuilder) encodeExtensions(extensions api.Extensions) string {
 	var exts string
 
@@ -73,82 +150,95 @@ func (rb requestBuilder) encodeExtensions(extensions api.Extensions) string {
 	return exts
 }
 
-func (rb requestBuilder) kindToHTTPRequest(baseURL string, kind kind) (*http.Request, error) {
+func (rb requestBuilder) kindToHTTPRequest(ctx context.Context, baseURL *url.URL, kind kind, body io.Reader) (*http.Request, error) {
 	switch kind {
 	case auth:
-		return http.NewRequest(http.MethodGet, baseURL+authzEndpoint, nil)
+		return http.NewRequestWithContext(ctx, http.MethodGet, endpointURL(baseURL, rb.endpoints.Authorize).String(), nil)
 	case authRep:
-		return http.NewRequest(http.MethodGet, baseURL+authRepEndpoint, nil)
+		return http.NewRequestWithContext(ctx, http.MethodGet, endpointURL(baseURL, rb.endpoints.AuthRep).String(), nil)
 	case report:
-		return http.NewRequest(http.MethodPost, baseURL+reportEndpoint, nil)
+		return http.NewRequestWithContext(ctx, http.MethodPost, endpointURL(baseURL, rb.endpoints.Report).String(), body)
 	case oauthAuth:
-		return http.NewRequest(http.MethodGet, baseURL+oauthAuthzEndpoint, nil)
+		return http.NewRequestWithContext(ctx, http.MethodGet, endpointURL(baseURL, rb.endpoints.OauthAuthorize).String(), nil)
 	case oauthAuthRep:
-		return http.NewRequest(http.MethodGet, baseURL+oauthAuthRepEndpoint, nil)
+		return http.NewRequestWithContext(ctx, http.MethodGet, endpointURL(baseURL, rb.endpoints.OauthAuthRep).String(), nil)
 	default:
 		return nil, fmt.Errorf("unknown api call kind provided")
 	}
 }
 
-func (rb requestBuilder) authToValues(auth api.ClientAuth) url.Values {
-	values := make(url.Values)
-	values.Add(string(auth.Type), auth.Value)
-	return values
-}
-
-func (rb requestBuilder) metricsToValues(m api.Metrics) url.Values {
-	values := make(url.Values, len(m))
-
-	for metricName, incrementBy := range m {
-		key := fmt.Sprintf("usage[%s]", metricName)
-		value := strconv.Itoa(incrementBy)
-		values.Add(key, value)
+// endpointURL returns a copy of the pre-parsed baseURL with the path of the endpoint appended
+func endpointURL(baseURL *url.URL, path string) *url.URL {
+	u := *baseURL
+	u.Path = baseURL.Path + path
+	if baseURL.RawPath != "" {
+		u.RawPath = baseURL.RawPath + path
 	}
-	return values
+	return &u
 }
 
-// paramsToValues inspects the Params (p) for non-empty values with json tags, and appends them to returned values
-func (rb requestBuilder) paramsToValues(p api.Params) url.Values {
-	values := make(url.Values)
-
+// forEachParam inspects the Params (p) for non-empty values with json tags, calling fn with the tag and value of each
+func (rb requestBuilder) forEachParam(p api.Params, fn func(key string, value string)) {
 	val := reflect.ValueOf(p)
 	for i := 0; i < val.Type().NumField(); i++ {
 		if tag, ok := val.Type().Field(i).Tag.Lookup("json"); ok {
-			if valueToAdd := val.Field(i).String(); valueToAdd != "" {
-				values.Add(tag, valueToAdd)
+			if value := val.Field(i).String(); value != "" {
+				fn(tag, value)
 			}
 		}
 	}
-	return values
 }
 
-func (rb requestBuilder) serviceToValues(s api.Service) url.Values {
-	return url.Values{serviceIDKey: []string{string(s)}}
-}
+// addTransaction adds the values for a transaction in the format required for batch reporting, this differs from the
+// expected query for both auth endpoints so must be dealt with accordingly
+func (rb requestBuilder) addTransaction(values url.Values, index int, t api.Transaction) {
+	prefix := "transactions[" + strconv.Itoa(index) + "]"
 
-// transactionToValues formats the values correctly for batch reporting, this differs from the expected query for
-// both auth endpoints so must be dealt with accordingly
-func (rb requestBuilder) transactionToValues(index int, t api.Transaction) url.Values {
-	values := make(url.Values)
-	paramValues := rb.paramsToValues(t.Params)
+	rb.forEachParam(t.Params, func(key string, value string) {
+		values.Set(prefix+"["+key+"]", value)
+	})
 
-	for k, v := range paramValues {
-		values.Add(fmt.Sprintf("transactions[%d][%s]", index, k), v[0])
-	}
-
-	for k, v := range t.Metrics {
-		values.Add(fmt.Sprintf("transactions[%d][usage][%s]", index, k), strconv.Itoa(v))
+	for metricName, incrementBy := range t.Metrics {
+		if rb.omitMetric(incrementBy) || !rb.isReportable(incrementBy) {
+			continue
+		}
+		values.Set(prefix+"[usage]["+metricName+"]", strconv.Itoa(incrementBy))
 	}
 
 	if t.Timestamp != 0 {
-		values.Add(fmt.Sprintf("transactions[%d][timestamp]", index), strconv.FormatInt(t.Timestamp, 10))
+		values.Set(prefix+"[timestamp]", strconv.FormatInt(t.Timestamp, 10))
 	}
-	return values
+
+	rb.addLog(values, prefix, t.Log)
 }
 
-func (rb requestBuilder) joinValues(joinExisting url.Values, to url.Values) url.Values {
-	for k, v := range joinExisting {
-		to[k] = v
+// addLog adds the non-empty fields of the log as 'log[field]' keys, nested within prefix when reporting a batch of
+// transactions. The values are escaped when the values are encoded so may contain arbitrary text.
+func (rb requestBuilder) addLog(values url.Values, prefix string, l api.Log) {
+	key := "log"
+	if prefix != "" {
+		key = prefix + "[log]"
+	}
+
+	if l.Request != "" {
+		values.Set(key+"[request]", l.Request)
 	}
-	return to
+	if l.Response != "" {
+		values.Set(key+"[response]", l.Response)
+	}
+	if l.Code != 0 {
+		values.Set(key+"[code]", strconv.Itoa(l.Code))
+	}
+}
+
+// isReportable returns false if a metric with the given value should be discarded when reporting usage
+// because only positive values have been configured to be reported
+func (rb requestBuilder) isReportable(value int) bool {
+	return !rb.onlyPositiveMetrics || value > 0
+}
+
+// omitMetric returns true if a metric with the given value should be omitted from the request.
+// Zero valued metrics have no effect in backend so are omitted unless configured otherwise.
+func (rb requestBuilder) omitMetric(value int) bool {
+	return value == 0 && !rb.includeZeroMetrics
 }
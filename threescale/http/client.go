@@ -1,14 +1,22 @@
 package http
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
-	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httputil"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/3scale/3scale-go-client/threescale"
@@ -17,11 +25,11 @@ import (
 )
 
 const (
-	authzEndpoint   = "/transactions/authorize.xml"
-	oauthAuthzEndpoint = "/transactions/oauth_authorize.xml"
-	authRepEndpoint = "/transactions/authrep.xml"
+	authzEndpoint        = "/transactions/authorize.xml"
+	oauthAuthzEndpoint   = "/transactions/oauth_authorize.xml"
+	authRepEndpoint      = "/transactions/authrep.xml"
 	oauthAuthRepEndpoint = "/transactions/oauth_authrep.xml"
-	reportEndpoint  = "/transactions.xml"
+	reportEndpoint       = "/transactions.xml"
 
 	statusEndpoint = "/status"
 )
@@ -29,58 +37,186 @@ const (
 const (
 	defaultBackendUrl = "https://su1.3scale.net:443"
 	defaultTimeout    = 10 * time.Second
+	// defaultMaxBatchSize is the maximum number of transactions sent in a single report request unless overridden
+	defaultMaxBatchSize = 1000
+	// defaultMaxResponseBytes is the maximum size of a response body which will be read unless overridden
+	defaultMaxResponseBytes = 4 << 20
 
 	serviceIDKey = "service_id"
+	timestampKey = "timestamp"
 
 	enableExtensions = "3scale-options"
+	// idempotencyKeyHeaderKey allows backend to deduplicate retried reports - see WithIdempotencyKey
+	idempotencyKeyHeaderKey = "Idempotency-Key"
+	// clientInfoHeaderKey identifies the integration making the request - see WithClientMetadata
+	clientInfoHeaderKey = "3scale-client-info"
 	// limitRemainingHeaderKey has a value set to the remaining calls in a current period
 	limitRemainingHeaderKey = "3scale-limit-remaining"
 	// limitResetHeaderKey has a value set to an integer stating the amount of seconds left for the current limiting period to elapse
 	limitResetHeaderKey = "3scale-limit-reset"
 	// RejectionReasonHeader - This is used by authorization endpoints to provide a header that provides an error code
 	// describing the different reasons an authorization can be denied.
-	RejectionReasonHeaderExtension = "rejection_reason_header"
+	RejectionReasonHeaderExtension = api.RejectionReasonHeaderExtension
 	// NoBodyExtension instructs backend to avoid generating response bodies for certain endpoints.
 	// In particular, this is useful to avoid generating large response in the authorization endpoints
-	NoBodyExtension = "no_body"
+	NoBodyExtension = api.NoBodyExtension
 
-	httpReqErrText = "error building http transaction"
+	// serviceTokenInvalidCode is the error code returned by backend when the service token is invalid
+	serviceTokenInvalidCode = "service_token_invalid"
+	// applicationKeyInvalidCode is the error code returned by backend when the application key is invalid
+	applicationKeyInvalidCode = "application_key_invalid"
+
+	// maxBodySnippet is the maximum number of bytes of an undecodable response body included in an error
+	maxBodySnippet = 256
 
 	// a parsable time format used to convert Ruby time to time type
 	timeLayout = "2006-01-02 15:04:05 -0700"
 )
 
-var (
-	errHttpReq = errors.New(httpReqErrText)
-)
+// timeLayouts are tried in order when parsing the timestamps of usage reports, tolerating variations such as a 'Z'
+// suffix in place of the offset. Fractional seconds are accepted by each layout.
+var timeLayouts = []string{
+	timeLayout,
+	time.RFC3339,
+	"2006-01-02 15:04:05Z07:00",
+}
+
+// ensure Client satisfies the canonical threescale.Client interface
+var _ threescale.Client = (*Client)(nil)
 
 // Client interacts with 3scale Service Management API and implements a threescale client
 type Client struct {
 	backendHost string
 	baseURL     string
-	httpClient  *http.Client
+	// backendURL is baseURL parsed once at construction to avoid parsing it for every request
+	backendURL *url.URL
+	// httpClient must be accessed via getHTTPClient as it may be replaced by SetHTTPClient
+	httpClient *http.Client
+	// httpClientMutex guards httpClient
+	httpClientMutex sync.RWMutex
+	options         ClientOptions
+	endpoints       EndpointConfig
+
+	// asyncReports bounds the number of reports in flight via ReportAsync and is created on first use
+	asyncReports chan struct{}
+	asyncOnce    sync.Once
+	asyncWG      sync.WaitGroup
+	// asyncMutex guards closed and the registration of reports with asyncWG
+	asyncMutex sync.Mutex
+	closed     bool
+
+	// dumpMutex serialises writes made by WithRequestResponseDump
+	dumpMutex sync.Mutex
+}
+
+// EndpointConfig defines the paths, relative to the backend URL, of the endpoints called by the Client.
+// This is useful where backend is deployed behind a reverse proxy which prefixes or renames routes.
+// Any path left empty falls back to its default value. See DefaultEndpointConfig.
+type EndpointConfig struct {
+	Authorize string
+	AuthRep   string
+	Report    string
+	Status    string
+	// DEPRECATED - only used by OauthAuthorize
+	OauthAuthorize string
+	// DEPRECATED - only used by OauthAuthRep
+	OauthAuthRep string
+}
+
+// DefaultEndpointConfig returns the paths of the endpoints exposed by 3scale backend
+func DefaultEndpointConfig() EndpointConfig {
+	return EndpointConfig{
+		Authorize:      authzEndpoint,
+		AuthRep:        authRepEndpoint,
+		Report:         reportEndpoint,
+		Status:         statusEndpoint,
+		OauthAuthorize: oauthAuthzEndpoint,
+		OauthAuthRep:   oauthAuthRepEndpoint,
+	}
+}
+
+// withDefaults returns a copy of the EndpointConfig with any empty path set to its default value
+func (ec EndpointConfig) withDefaults() EndpointConfig {
+	defaults := DefaultEndpointConfig()
+	for _, path := range []struct {
+		value    *string
+		fallback string
+	}{
+		{&ec.Authorize, defaults.Authorize},
+		{&ec.AuthRep, defaults.AuthRep},
+		{&ec.Report, defaults.Report},
+		{&ec.Status, defaults.Status},
+		{&ec.OauthAuthorize, defaults.OauthAuthorize},
+		{&ec.OauthAuthRep, defaults.OauthAuthRep},
+	} {
+		if *path.value == "" {
+			*path.value = path.fallback
+		}
+	}
+	return ec
 }
 
 // NewClient returns a pointer to a Client providing some verification and sanity checking
 // of the backendURL input. backendURL should take one of the following formats:
-//	* http://example.com - provided scheme with no port
-//	* https://example.com:443 - provided scheme and defined port
-func NewClient(backendURL string, httpClient *http.Client) (*Client, error) {
+//   - http://example.com - provided scheme with no port
+//   - https://example.com:443 - provided scheme and defined port
+//
+// If httpClient is nil, an http.Client is constructed and configured using the provided ClientOption(s).
+// Such a client respects the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables unless WithProxy is provided.
+// Where httpClient is provided, it is used as is and transport related options, including WithProxy, are ignored.
+func NewClient(backendURL string, httpClient *http.Client, options ...ClientOption) (*Client, error) {
 	url, err := verifyBackendUrl(backendURL)
 	if err != nil {
 		return nil, err
 	}
 
+	clientOptions := newClientOptions(options...)
+	if clientOptions.proxyURL != "" {
+		if _, err := parseProxyURL(clientOptions.proxyURL); err != nil {
+			return nil, err
+		}
+	}
+
+	if clientOptions.backendAddr != "" {
+		WithDialOverride(url.Hostname(), clientOptions.backendAddr)(clientOptions)
+	}
+
+	if httpClient == nil {
+		httpClient = defaultHttpClient(clientOptions)
+	}
+
 	return &Client{
 		backendHost: url.Hostname(),
 		baseURL:     backendURL,
+		backendURL:  url,
 		httpClient:  httpClient,
+		options:     *clientOptions,
 	}, nil
 }
 
+// NewClientWithConfig returns a pointer to a Client, as per NewClient, which calls the endpoints defined by the
+// provided EndpointConfig rather than the defaults.
+func NewClientWithConfig(backendURL string, httpClient *http.Client, config EndpointConfig, options ...ClientOption) (*Client, error) {
+	c, err := NewClient(backendURL, httpClient, options...)
+	if err != nil {
+		return nil, err
+	}
+	c.endpoints = config.withDefaults()
+	return c, nil
+}
+
+// NewClientWithTransport returns a pointer to a Client which uses an http.Client configured with the provided
+// transport and the default timeout. See NewTransport for building a transport from a TransportConfig.
+func NewClientWithTransport(backendURL string, transport *http.Transport, options ...ClientOption) (*Client, error) {
+	return NewClient(backendURL, &http.Client{
+		Transport: transport,
+		Timeout:   defaultTimeout,
+	}, options...)
+}
+
 // NewDefaultClient returns a pointer to Client which is configured for 3scale SaaS platform.
-func NewDefaultClient() (*Client, error) {
-	return NewClient(defaultBackendUrl, defaultHttpClient())
+func NewDefaultClient(options ...ClientOption) (*Client, error) {
+	return NewClient(defaultBackendUrl, nil, options...)
 }
 
 // Authorize is a read-only operation to authorize an application with the authentication provided in the transaction params
@@ -93,6 +229,81 @@ func (c *Client) AuthorizeWithOptions(apiCall threescale.Request, options ...Opt
 	return c.doAuthOrAuthRep(apiCall, auth, newOptions(options...))
 }
 
+// AuthorizeWithContext provides the same behaviour as Authorize, bounding the remote call with the provided context.
+// This is equivalent to calling AuthorizeWithOptions with WithContext.
+func (c *Client) AuthorizeWithContext(ctx context.Context, apiCall threescale.Request) (*threescale.AuthorizeResult, error) {
+	return c.AuthorizeWithOptions(apiCall, WithContext(ctx))
+}
+
+// AuthorizeDecision authorizes the request and reduces the result to an allow (true) or deny (false) decision.
+// The policy determines the decision when backend is unavailable. See threescale.Decide
+func (c *Client) AuthorizeDecision(apiCall threescale.Request, policy threescale.FailurePolicy, options ...Option) bool {
+	result, err := c.AuthorizeWithOptions(apiCall, options...)
+	return threescale.Decide(result, err, policy)
+}
+
+// Enforce authorizes and reports the request, as per AuthRep, reducing the result to the decision a gateway should
+// enforce. The 'limit_headers' and 'rejection_reason_header' extensions are always enabled so that the status, reason
+// and time until limits reset can be determined. See threescale.AuthorizeResult.Enforcement
+func (c *Client) Enforce(apiCall threescale.Request, options ...Option) (*threescale.EnforcementDecision, error) {
+	apiCall = withExtension(apiCall, api.LimitExtension)
+	apiCall = withExtension(apiCall, RejectionReasonHeaderExtension)
+
+	result, err := c.AuthRepWithOptions(apiCall, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	decision := result.Enforcement(time.Now())
+	return &decision, nil
+}
+
+// AuthorizeWithFallback authorizes the request using the primary client auth. Should backend reject the primary auth
+// as an invalid service token, the request is retried once using the fallback auth, for example a provider key.
+// This is useful during service token rotation. Any auth set on the request itself is ignored.
+func (c *Client) AuthorizeWithFallback(primary api.ClientAuth, fallback api.ClientAuth, apiCall threescale.Request, options ...Option) (*threescale.AuthorizeResult, error) {
+	apiCall.Auth = primary
+	result, err := c.AuthorizeWithOptions(apiCall, options...)
+	if err != nil || result.ErrorCode != serviceTokenInvalidCode {
+		return result, err
+	}
+
+	apiCall.Auth = fallback
+	return c.AuthorizeWithOptions(apiCall, options...)
+}
+
+// AuthorizeWithAppKeys authorizes the request with each of the application keys provided in the AppKey of the first
+// transaction, as a comma separated list (see api.Params.AppKeys), in turn. Should backend reject a key as invalid,
+// the request is retried with the next key. Returns the result for the first key which was not rejected as invalid,
+// along with that key. Where every key is rejected, the result for the last key is returned, along with that key.
+func (c *Client) AuthorizeWithAppKeys(apiCall threescale.Request, options ...Option) (*threescale.AuthorizeResult, string, error) {
+	if err := apiCall.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	keys := apiCall.Transactions[0].Params.AppKeys()
+	if len(keys) == 0 {
+		result, err := c.AuthorizeWithOptions(apiCall, options...)
+		return result, "", err
+	}
+
+	// avoid modifying the transactions of the caller
+	transactions := append([]api.Transaction(nil), apiCall.Transactions...)
+	apiCall.Transactions = transactions
+
+	var result *threescale.AuthorizeResult
+	var err error
+	for _, key := range keys {
+		transactions[0].Params = transactions[0].Params.WithAppKey(key)
+
+		result, err = c.AuthorizeWithOptions(apiCall, options...)
+		if err != nil || result.ErrorCode != applicationKeyInvalidCode {
+			return result, key, err
+		}
+	}
+	return result, keys[len(keys)-1], err
+}
+
 // Deprecated - DO NOT use in new code.
 func (c *Client) OauthAuthorize(apiCall threescale.Request) (*threescale.AuthorizeResult, error) {
 	return c.OauthAuthorizeWithOptions(apiCall)
@@ -114,6 +325,37 @@ func (c *Client) AuthRepWithOptions(apiCall threescale.Request, options ...Optio
 	return c.doAuthOrAuthRep(apiCall, authRep, newOptions(options...))
 }
 
+// AuthorizeUserKey authorizes the application identified by the user key (API key) against the service,
+// for the provided metrics. It is shorthand for calling AuthorizeWithOptions with a single transaction.
+func (c *Client) AuthorizeUserKey(service api.Service, userKey string, auth api.ClientAuth, metrics api.Metrics, options ...Option) (*threescale.AuthorizeResult, error) {
+	return c.AuthorizeWithOptions(singleTransaction(service, api.Params{UserKey: userKey}, auth, metrics), options...)
+}
+
+// AuthorizeAppID authorizes the application identified by the application id and, optionally, application key
+// against the service, for the provided metrics. It is shorthand for calling AuthorizeWithOptions with a single transaction.
+func (c *Client) AuthorizeAppID(service api.Service, appID string, appKey string, auth api.ClientAuth, metrics api.Metrics, options ...Option) (*threescale.AuthorizeResult, error) {
+	return c.AuthorizeWithOptions(singleTransaction(service, api.Params{AppID: appID, AppKey: appKey}, auth, metrics), options...)
+}
+
+// AuthRepUserKey provides the same behaviour as AuthorizeUserKey, reporting the metrics should the application be authorized
+func (c *Client) AuthRepUserKey(service api.Service, userKey string, auth api.ClientAuth, metrics api.Metrics, options ...Option) (*threescale.AuthorizeResult, error) {
+	return c.AuthRepWithOptions(singleTransaction(service, api.Params{UserKey: userKey}, auth, metrics), options...)
+}
+
+// AuthRepAppID provides the same behaviour as AuthorizeAppID, reporting the metrics should the application be authorized
+func (c *Client) AuthRepAppID(service api.Service, appID string, appKey string, auth api.ClientAuth, metrics api.Metrics, options ...Option) (*threescale.AuthorizeResult, error) {
+	return c.AuthRepWithOptions(singleTransaction(service, api.Params{AppID: appID, AppKey: appKey}, auth, metrics), options...)
+}
+
+// singleTransaction builds a Request against the service containing a single transaction
+func singleTransaction(service api.Service, params api.Params, auth api.ClientAuth, metrics api.Metrics) threescale.Request {
+	return threescale.Request{
+		Auth:         auth,
+		Service:      service,
+		Transactions: []api.Transaction{{Params: params, Metrics: metrics}},
+	}
+}
+
 // Deprecated - DO NOT use in new code.
 func (c *Client) OauthAuthRep(apiCall threescale.Request) (*threescale.AuthorizeResult, error) {
 	return c.OauthAuthRepWithOptions(apiCall)
@@ -124,6 +366,8 @@ func (c *Client) OauthAuthRepWithOptions(apiCall threescale.Request, options ...
 	return c.doAuthOrAuthRep(apiCall, oauthAuthRep, newOptions(options...))
 }
 
+// Report the transactions to 3scale backend with the authentication provided in the transactions params
+// Large reports are split into chunks of at most 1000 transactions which are reported sequentially
 func (c *Client) Report(apiCall threescale.Request) (*threescale.ReportResult, error) {
 	return c.ReportWithOptions(apiCall)
 }
@@ -133,78 +377,515 @@ func (c *Client) ReportWithOptions(apiCall threescale.Request, options ...Option
 	return c.doReport(apiCall, newOptions(options...))
 }
 
+// ReportWithContext provides the same behaviour as Report, bounding the remote call(s) with the provided context.
+// This is equivalent to calling ReportWithOptions with WithContext.
+func (c *Client) ReportWithContext(ctx context.Context, apiCall threescale.Request) (*threescale.ReportResult, error) {
+	return c.ReportWithOptions(apiCall, WithContext(ctx))
+}
+
 // GetPeer returns the hostname of the backend for the client
 func (c *Client) GetPeer() string {
 	return c.backendHost
 }
 
+// GetBaseURL returns the URL of the backend for the client, as provided at construction
+func (c *Client) GetBaseURL() string {
+	return c.baseURL
+}
+
+// GetPort returns the port used to connect to the backend for the client. Where the backend URL did not
+// include a port, the default port for the scheme is returned. Returns 0 if the port cannot be determined.
+func (c *Client) GetPort() int {
+	backendURL, err := c.parsedBaseURL()
+	if err != nil {
+		return 0
+	}
+
+	if port := backendURL.Port(); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return 0
+		}
+		return p
+	}
+
+	switch backendURL.Scheme {
+	case "https":
+		return 443
+	case "http":
+		return 80
+	default:
+		return 0
+	}
+}
+
+// SetHTTPClient replaces the http.Client used for requests made by the Client. It is safe to call SetHTTPClient
+// concurrently with requests - requests which are in flight complete using the http.Client they started with, while
+// subsequent requests use the provided http.Client. If httpClient is nil, an http.Client is constructed as per NewClient.
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	if httpClient == nil {
+		httpClient = defaultHttpClient(&c.options)
+	}
+
+	c.httpClientMutex.Lock()
+	defer c.httpClientMutex.Unlock()
+	c.httpClient = httpClient
+}
+
+func (c *Client) getHTTPClient() *http.Client {
+	c.httpClientMutex.RLock()
+	defer c.httpClientMutex.RUnlock()
+	return c.httpClient
+}
+
+// Close waits for any reports in flight via ReportAsync to complete, before releasing any idle connections held
+// by the underlying http.Client's transport, where the transport supports doing so.
+// The Client should not be used after calling Close, and ReportAsync returns ErrClientClosed.
+// Close always returns a nil error.
+func (c *Client) Close() error {
+	c.asyncMutex.Lock()
+	c.closed = true
+	c.asyncMutex.Unlock()
+
+	c.asyncWG.Wait()
+	c.getHTTPClient().CloseIdleConnections()
+	return nil
+}
+
 // GetVersion returns the version of the backend for this client (remote call)
 func (c *Client) GetVersion() (string, error) {
-	var version string
+	return c.GetVersionWithContext(context.Background())
+}
+
+// GetVersionWithContext provides the same behaviour as GetVersion, bounding the remote call with the provided context
+func (c *Client) GetVersionWithContext(ctx context.Context) (string, error) {
+	status, err := c.GetStatusWithContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return status.BackendVersion, nil
+}
+
+// GetStatus returns the status and version of the backend for this client (remote call)
+func (c *Client) GetStatus() (*threescale.BackendStatus, error) {
+	return c.GetStatusWithContext(context.Background())
+}
+
+// GetStatusWithContext provides the same behaviour as GetStatus, bounding the remote call with the provided context
+func (c *Client) GetStatusWithContext(ctx context.Context) (*threescale.BackendStatus, error) {
 	var statusResponse internal.StatusResponse
 
-	req, err := http.NewRequest(http.MethodGet, c.baseURL+statusEndpoint, nil)
+	req, err := c.newStatusRequest(ctx)
 	if err != nil {
-		return version, fmt.Errorf("failed to build request for status endpoint - %s", err.Error())
+		return nil, err
 	}
-	req.Header.Set("Accept", "application/xml")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return version, fmt.Errorf("failed to fetch backend version - %s", err.Error())
+		return nil, fmt.Errorf("failed to fetch backend status - %w", &threescale.TransportError{Err: err})
 	}
 	defer resp.Body.Close()
 
-	err = json.NewDecoder(resp.Body).Decode(&statusResponse)
+	body, err := c.readBody(resp)
 	if err != nil {
-		return version, fmt.Errorf("failed to fetch backend version - %s", err.Error())
+		return nil, fmt.Errorf("failed to fetch backend status - %w - %s", threescale.ErrDecode, err.Error())
+	}
+
+	if err := json.Unmarshal(body, &statusResponse); err != nil {
+		return nil, fmt.Errorf("failed to fetch backend status - %w", decodeErr(err, resp.StatusCode, body))
 	}
 
-	return statusResponse.Version.Backend, nil
+	return &threescale.BackendStatus{
+		Status:         statusResponse.Status,
+		BackendVersion: statusResponse.Version.Backend,
+	}, nil
+}
+
+// Ping performs a lightweight liveness check against the backend for this client (remote call).
+// Returns nil if backend responds with a 2xx status code. The response body is not decoded.
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := c.newStatusRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to ping backend - %w", &threescale.TransportError{Err: err})
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("failed to ping backend - %w", backendError(resp))
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to ping backend - unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+func (c *Client) newStatusRequest(ctx context.Context) (*http.Request, error) {
+	baseURL, err := c.parsedBaseURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request for status endpoint - %w - %s", threescale.ErrHTTPBuild, err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpointURL(baseURL, c.endpoints.withDefaults().Status).String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request for status endpoint - %w - %s", threescale.ErrHTTPBuild, err.Error())
+	}
+	req.Header.Set("Accept", "application/xml")
+	req.Header.Set("User-Agent", c.userAgent())
+	if c.options.clientInfo != "" {
+		req.Header.Set(clientInfoHeaderKey, c.options.clientInfo)
+	}
+	return req, nil
 }
 
 func (c *Client) doAuthOrAuthRep(apiCall threescale.Request, kind kind, options *Options) (*threescale.AuthorizeResult, error) {
-	req, err := requestBuilder{}.build(apiCall, c.baseURL, kind)
+	if err := apiCall.Validate(); err != nil {
+		return nil, err
+	}
+
+	apiCall = c.withDefaultExtensions(apiCall)
+
+	if options.rejectionReasonHeader {
+		apiCall = withExtension(apiCall, RejectionReasonHeaderExtension)
+	}
+
+	rb := c.requestBuilder()
+	rb.authorizeTimestamp = options.authorizeTimestamp
+
+	baseURL, err := c.backendFor(options)
 	if err != nil {
 		return nil, c.wrapError(err)
 	}
 
-	return c.executeAuthCall(req, apiCall.Extensions, options)
+	req, err := rb.build(options.context, apiCall, baseURL, kind)
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+
+	c.annotateRequest(req, options)
+
+	result, err := c.executeAuthCall(req, kind, apiCall.Extensions, options)
+	if result != nil {
+		result.RequestURL = redactURL(req.URL)
+
+		if options.sortUsageReports {
+			if options.sortAscending {
+				result.UsageReports.OrderByAscendingGranularity()
+			} else {
+				result.UsageReports.OrderByDescendingGranularity()
+			}
+		}
+	}
+	return result, err
 }
 
+// doReport splits the transactions into chunks no larger than the configured batch size and reports each chunk
+// sequentially. The aggregated result is only accepted if every chunk has been accepted. Reporting stops at the
+// first chunk which fails or is rejected.
 func (c *Client) doReport(apiCall threescale.Request, options *Options) (*threescale.ReportResult, error) {
-	req, err := requestBuilder{}.build(apiCall, c.baseURL, report)
+	if err := apiCall.Validate(); err != nil {
+		return nil, err
+	}
+
+	apiCall = c.withDefaultExtensions(apiCall)
+
+	if options.flatUsageHierarchy != nil {
+		apiCall = withFlatUsage(apiCall, options.flatUsageHierarchy)
+	}
+
+	batchSize := options.maxBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultMaxBatchSize
+	}
+
+	if len(apiCall.Transactions) <= batchSize {
+		result, err := c.doReportChunk(apiCall, options, options.idempotencyKey)
+		if err == nil && result.Accepted {
+			result.AcceptedIndexes = indexes(len(apiCall.Transactions))
+		}
+		return result, err
+	}
+
+	chunks := (len(apiCall.Transactions) + batchSize - 1) / batchSize
+	var result *threescale.ReportResult
+	for chunk := 0; chunk < chunks; chunk++ {
+		start := chunk * batchSize
+		end := start + batchSize
+		if end > len(apiCall.Transactions) {
+			end = len(apiCall.Transactions)
+		}
+
+		// avoid sending further chunks once the caller has given up
+		if options.context != nil && options.context.Err() != nil {
+			return partialReportResult(nil, start), fmt.Errorf("report cancelled before chunk %d of %d - %w",
+				chunk, chunks, options.context.Err())
+		}
+
+		chunkCall := apiCall
+		chunkCall.Transactions = apiCall.Transactions[start:end]
+
+		var err error
+		// each chunk is a distinct request so must be identified by its own key
+		var idempotencyKey string
+		if options.idempotencyKey != "" {
+			idempotencyKey = fmt.Sprintf("%s-%d", options.idempotencyKey, chunk)
+		}
+
+		result, err = c.doReportChunk(chunkCall, options, idempotencyKey)
+		if err != nil {
+			return partialReportResult(result, start), fmt.Errorf("failed to report chunk %d of %d - %w", chunk, chunks, err)
+		}
+
+		if !result.Accepted {
+			// exceeding limits is a denial rather than an error, as it is when the report is sent in a single request
+			if result.IsLimitExceeded() {
+				return partialReportResult(result, start), nil
+			}
+			return partialReportResult(result, start), fmt.Errorf("report chunk %d of %d was not accepted - %s",
+				chunk, chunks, result.ErrorCode)
+		}
+	}
+
+	result.AcceptedIndexes = indexes(len(apiCall.Transactions))
+	return result, nil
+}
+
+// partialReportResult annotates the result of an interrupted report with the number of transactions already accepted
+func partialReportResult(result *threescale.ReportResult, accepted int) *threescale.ReportResult {
+	if result == nil {
+		if accepted == 0 {
+			return nil
+		}
+		result = &threescale.ReportResult{}
+	}
+	result.Accepted = false
+	result.PartialCount = accepted
+	result.AcceptedIndexes = indexes(accepted)
+	return result
+}
+
+// indexes returns the indexes of the first n transactions of a report, or nil if n is 0
+func indexes(n int) []int {
+	if n == 0 {
+		return nil
+	}
+
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	return idx
+}
+
+// withFlatUsage returns a copy of the request with the flat_usage extension enabled and the metrics for each
+// transaction expanded using the provided hierarchy. The original request is left untouched.
+func withFlatUsage(apiCall threescale.Request, hierarchy api.Hierarchy) threescale.Request {
+	apiCall = withExtension(apiCall, api.FlatUsageExtension)
+
+	transactions := make([]api.Transaction, len(apiCall.Transactions))
+	for i, transaction := range apiCall.Transactions {
+		transaction.Metrics = transaction.Metrics.AddHierarchyToMetrics(hierarchy)
+		transactions[i] = transaction
+	}
+	apiCall.Transactions = transactions
+
+	return apiCall
+}
+
+// withExtension returns a copy of the request with the extension enabled. The original request is left untouched.
+func withExtension(apiCall threescale.Request, key string) threescale.Request {
+	extensions := make(api.Extensions, len(apiCall.Extensions)+1)
+	for k, v := range apiCall.Extensions {
+		extensions[k] = v
+	}
+	extensions[key] = "1"
+	apiCall.Extensions = extensions
+	return apiCall
+}
+
+// withDefaultExtensions returns a copy of the request with the default extensions of the Client merged into those
+// of the request. Extensions provided by the request take precedence. The original request is left untouched.
+func (c *Client) withDefaultExtensions(apiCall threescale.Request) threescale.Request {
+	if len(c.options.defaultExtensions) == 0 {
+		return apiCall
+	}
+
+	extensions := make(api.Extensions, len(c.options.defaultExtensions)+len(apiCall.Extensions))
+	for k, v := range c.options.defaultExtensions {
+		extensions[k] = v
+	}
+	for k, v := range apiCall.Extensions {
+		extensions[k] = v
+	}
+	apiCall.Extensions = extensions
+	return apiCall
+}
+
+func (c *Client) doReportChunk(apiCall threescale.Request, options *Options, idempotencyKey string) (*threescale.ReportResult, error) {
+	baseURL, err := c.backendFor(options)
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+
+	req, err := c.requestBuilder().build(options.context, apiCall, baseURL, report)
 	if err != nil {
 		return nil, c.wrapError(err)
 	}
 
-	return c.executeReportCall(req, apiCall.Extensions, options)
+	if idempotencyKey != "" {
+		req.Header.Set(idempotencyKeyHeaderKey, idempotencyKey)
+	}
+
+	c.annotateRequest(req, options)
+
+	result, err := c.executeReportCall(req, apiCall.Extensions, options)
+	if result != nil {
+		result.RequestURL = redactURL(req.URL)
+	}
+	return result, err
+}
+
+// annotateRequest applies the request level customisations provided via options to the built request
+func (c *Client) annotateRequest(req *http.Request, options *Options) {
+	if options == nil {
+		return
+	}
+	options.traceContext.setHeaders(req)
+
+	if options.compression {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	for _, modify := range options.requestModifiers {
+		modify(req)
+	}
+}
+
+// parsedBaseURL returns the URL of the backend parsed at construction, parsing baseURL
+// where the Client has not been built by a constructor
+func (c *Client) parsedBaseURL() (*url.URL, error) {
+	if c.backendURL != nil {
+		return c.backendURL, nil
+	}
+	return url.Parse(c.baseURL)
+}
+
+// backendFor returns the URL of the backend to which a call is made, which is that of the Client
+// unless overridden via WithBackendURL
+func (c *Client) backendFor(options *Options) (*url.URL, error) {
+	if options != nil && options.backendURL != "" {
+		return verifyBackendUrl(options.backendURL)
+	}
+	return c.parsedBaseURL()
+}
+
+// peerFor returns the hostname of the backend to which the request has been sent
+func (c *Client) peerFor(req *http.Request, options *Options) string {
+	if options != nil && options.backendURL != "" {
+		return req.URL.Hostname()
+	}
+	return c.GetPeer()
+}
+
+func (c *Client) requestBuilder() requestBuilder {
+	return requestBuilder{
+		endpoints:           c.endpoints.withDefaults(),
+		onlyPositiveMetrics: c.options.reportOnlyPositiveDeltas,
+		includeZeroMetrics:  c.options.includeZeroMetrics,
+		clientInfo:          c.options.clientInfo,
+		userAgent:           c.userAgent(),
+		responseFormat:      c.options.responseFormat,
+	}
+}
+
+func (c *Client) userAgent() string {
+	if c.options.userAgent != "" {
+		return c.options.userAgent
+	}
+	return DefaultUserAgent
 }
 
-func (c *Client) executeAuthCall(req *http.Request, extensions api.Extensions, options *Options) (*threescale.AuthorizeResult, error) {
+func (c *Client) executeAuthCall(req *http.Request, kind kind, extensions api.Extensions, options *Options) (*threescale.AuthorizeResult, error) {
 	if options != nil && options.context != nil {
 		req = req.WithContext(options.context)
 	}
 
 	start := time.Now()
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return nil, err
+		return nil, &threescale.TransportError{Err: err}
 	}
 	requestDuration := time.Since(start)
 	defer resp.Body.Close()
 
-	go func() {
-		if options != nil && options.instrumentationCB != nil {
-			options.instrumentationCB(options.context, c.GetPeer(), resp.StatusCode, requestDuration)
+	c.instrument(withEndpoint(requestContext(req, resp), kind), c.peerFor(req, options), options, resp.StatusCode, requestDuration)
+
+	if options != nil && options.compression {
+		if err := decompressBody(resp); err != nil {
+			return nil, err
 		}
-	}()
+	}
+
+	if isRedirect(resp) {
+		return &threescale.AuthorizeResult{
+			Authorized:  false,
+			RawResponse: resp,
+			Latency:     requestDuration,
+		}, redirectError(resp)
+	}
+
+	result, err := c.handleAuthResp(resp, extensions, options != nil && options.skipBodyParsing)
+	if result != nil {
+		result.Latency = requestDuration
+	}
+	return result, err
+}
+
+// instrument calls the instrumentation callback, if provided, in a separate goroutine unless
+// synchronous instrumentation has been requested. ctx is the context of the outbound request and peer
+// the hostname of the backend to which it was sent.
+func (c *Client) instrument(ctx context.Context, peer string, options *Options, statusCode int, requestDuration time.Duration) {
+	if options == nil || options.instrumentationCB == nil {
+		return
+	}
 
+	if options.synchronousInstrumentation {
+		options.instrumentationCB(ctx, peer, statusCode, requestDuration)
+		return
+	}
+	go options.instrumentationCB(ctx, peer, statusCode, requestDuration)
+}
+
+// requestContext returns the context attached to the request which was sent to backend.
+// Where the http.Client applies a timeout, the request sent is a copy of req carrying a derived context
+// which is available from the response, so that is preferred.
+func requestContext(req *http.Request, resp *http.Response) context.Context {
+	if resp != nil && resp.Request != nil {
+		return resp.Request.Context()
+	}
+	return req.Context()
+}
+
+func (c *Client) handleAuthResp(resp *http.Response, extensions api.Extensions, skipBody bool) (*threescale.AuthorizeResult, error) {
 	if resp.StatusCode >= 500 {
 		return &threescale.AuthorizeResult{
 			Authorized:  false,
 			RawResponse: resp,
-		}, fmt.Errorf("unable to process request - status: %s", resp.Status)
+		}, backendError(resp)
+	}
+
+	if skipBody {
+		// the body is discarded rather than decoded, but must be read to allow the connection to be reused
+		if _, err := c.readBody(resp); err != nil {
+			return nil, fmt.Errorf("%w - %s", threescale.ErrDecode, err.Error())
+		}
+		return c.handleNoBodyExtensionForAuth(resp, extensions), nil
 	}
 
 	if val, ok := extensions[NoBodyExtension]; ok && val == "1" {
@@ -215,15 +896,29 @@ func (c *Client) executeAuthCall(req *http.Request, extensions api.Extensions, o
 }
 
 func (c *Client) handleAuthXMLResp(resp *http.Response, extensions api.Extensions) (*threescale.AuthorizeResult, error) {
-	var xmlResponse internal.AuthResponseXML
+	body, err := c.readBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("%w - %s", threescale.ErrDecode, err.Error())
+	}
 
-	if err := xml.NewDecoder(resp.Body).Decode(&xmlResponse); err != nil {
-		return nil, err
+	decode := decodeAuthXML
+	if c.isJSONResponse(resp) {
+		decode = decodeAuthJSON
+	}
+
+	xmlResponse, err := decode(body)
+	if err != nil {
+		return nil, decodeErr(err, resp.StatusCode, body)
+	}
+
+	usageReports, err := c.convertXmlUsageReports(xmlResponse.UsageReports.Reports)
+	if err != nil && c.options.strictTimeParsing {
+		return nil, fmt.Errorf("%w - %s", threescale.ErrDecode, err.Error())
 	}
 
 	return &threescale.AuthorizeResult{
 		Authorized:   xmlResponse.Authorized,
-		UsageReports: c.convertXmlUsageReports(xmlResponse.UsageReports.Reports),
+		UsageReports: usageReports,
 		ErrorCode: func(code string, resp *http.Response) string {
 			if headerCode := c.parseRejectionReasonHeader(resp); headerCode != "" {
 				return headerCode
@@ -242,49 +937,174 @@ func (c *Client) executeReportCall(req *http.Request, extensions api.Extensions,
 	}
 
 	start := time.Now()
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return nil, err
+		return nil, &threescale.TransportError{Err: err}
 	}
 	requestDuration := time.Since(start)
 	defer resp.Body.Close()
 
-	go func() {
-		if options != nil && options.instrumentationCB != nil {
-			options.instrumentationCB(options.context, c.GetPeer(), resp.StatusCode, requestDuration)
+	c.instrument(withEndpoint(requestContext(req, resp), report), c.peerFor(req, options), options, resp.StatusCode, requestDuration)
+
+	if options != nil && options.compression {
+		if err := decompressBody(resp); err != nil {
+			return nil, err
 		}
-	}()
+	}
 
-	// ensure response is in 2xx range
-	if !(resp.StatusCode >= 200 && resp.StatusCode <= 299) {
-		return c.handleReportingError(resp)
+	if isRedirect(resp) {
+		return &threescale.ReportResult{
+			Accepted:    false,
+			RawResponse: resp,
+			Latency:     requestDuration,
+		}, redirectError(resp)
 	}
 
-	return &threescale.ReportResult{
+	result, err := c.handleReportResp(resp, extensions)
+	if result != nil {
+		result.Latency = requestDuration
+	}
+	return result, err
+}
+
+func (c *Client) handleReportResp(resp *http.Response, extensions api.Extensions) (*threescale.ReportResult, error) {
+	result := &threescale.ReportResult{
 		Accepted:    true,
 		RawResponse: resp,
-	}, nil
+	}
+
+	var err error
+	// ensure response is in 2xx range
+	if !(resp.StatusCode >= 200 && resp.StatusCode <= 299) {
+		result, err = c.handleReportingError(resp, extensions)
+	}
+
+	if _, ok := extensions[api.LimitExtension]; ok && result != nil && resp.StatusCode < 500 {
+		result.RateLimits = c.handleRateLimitExtensions(resp)
+	}
+	return result, err
 }
 
-func (c *Client) handleReportingError(resp *http.Response) (*threescale.ReportResult, error) {
+func (c *Client) handleReportingError(resp *http.Response, extensions api.Extensions) (*threescale.ReportResult, error) {
 	if resp.StatusCode >= 500 {
 		return &threescale.ReportResult{
 			Accepted:    false,
 			RawResponse: resp,
-		}, fmt.Errorf("unable to process request - status: %s", resp.Status)
+		}, backendError(resp)
+	}
+
+	if val, ok := extensions[NoBodyExtension]; ok && val == "1" {
+		return c.handleNoBodyExtensionForReport(resp)
 	}
 
+	return c.handleReportErrorXMLResp(resp)
+}
+
+// handleNoBodyExtensionForReport falls back to the rejection reason header for the error code when backend
+// has honoured the no_body extension. Should a body be present, it will be decoded as normal.
+func (c *Client) handleNoBodyExtensionForReport(resp *http.Response) (*threescale.ReportResult, error) {
+	body, err := c.readBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("%w - %s", threescale.ErrDecode, err.Error())
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return &threescale.ReportResult{
+			Accepted:    false,
+			ErrorCode:   c.parseRejectionReasonHeader(resp),
+			RawResponse: resp,
+		}, nil
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return c.handleReportErrorXMLResp(resp)
+}
+
+func (c *Client) handleReportErrorXMLResp(resp *http.Response) (*threescale.ReportResult, error) {
+
 	var xmlResponse internal.ReportErrorXML
-	if err := xml.NewDecoder(resp.Body).Decode(&xmlResponse); err != nil {
-		return nil, err
+
+	body, err := c.readBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("%w - %s", threescale.ErrDecode, err.Error())
+	}
+
+	if c.isJSONResponse(resp) {
+		var jsonResponse internal.ReportErrorJSON
+		if err := json.Unmarshal(body, &jsonResponse); err != nil {
+			return nil, decodeErr(err, resp.StatusCode, body)
+		}
+		xmlResponse.Code = jsonResponse.Error.Code
+		xmlResponse.Text = jsonResponse.Error.Message
+	} else if err := xml.Unmarshal(body, &xmlResponse); err != nil {
+		return nil, decodeErr(err, resp.StatusCode, body)
 	}
 	return &threescale.ReportResult{
-		Accepted:    false,
-		ErrorCode:   xmlResponse.Code,
-		RawResponse: resp,
+		Accepted:        false,
+		ErrorCode:       xmlResponse.Code,
+		RejectionReason: xmlResponse.Text,
+		RawResponse:     resp,
 	}, nil
 }
 
+// decodeAuthXML decodes the XML response returned by the authorization endpoints
+func decodeAuthXML(body []byte) (internal.AuthResponseXML, error) {
+	var xmlResponse internal.AuthResponseXML
+	if err := xml.Unmarshal(body, &xmlResponse); err != nil {
+		return xmlResponse, err
+	}
+
+	if xmlResponse.XMLName.Local == "error" {
+		// some failures, such as an invalid provider key, are returned in the same format as report errors
+		var errResponse internal.ReportErrorXML
+		if err := xml.Unmarshal(body, &errResponse); err != nil {
+			return xmlResponse, err
+		}
+		xmlResponse.Code = errResponse.Code
+		xmlResponse.Reason = errResponse.Text
+	}
+	return xmlResponse, nil
+}
+
+// decodeAuthJSON decodes the JSON response returned by the authorization endpoints into its XML equivalent,
+// allowing the remainder of the response handling to be shared regardless of format
+func decodeAuthJSON(body []byte) (internal.AuthResponseXML, error) {
+	var xmlResponse internal.AuthResponseXML
+
+	var jsonResponse internal.AuthResponseJSON
+	if err := json.Unmarshal(body, &jsonResponse); err != nil {
+		return xmlResponse, err
+	}
+
+	if jsonResponse.Error != nil {
+		xmlResponse.Code = jsonResponse.Error.Code
+		xmlResponse.Reason = jsonResponse.Error.Message
+		return xmlResponse, nil
+	}
+
+	xmlResponse.Authorized = jsonResponse.Authorized
+	xmlResponse.Reason = jsonResponse.Reason
+	for _, report := range jsonResponse.UsageReports {
+		xmlResponse.UsageReports.Reports = append(xmlResponse.UsageReports.Reports, internal.UsageReportXML(report))
+	}
+	for metric, children := range jsonResponse.Hierarchy {
+		xmlResponse.Hierarchy.Metric = append(xmlResponse.Hierarchy.Metric, internal.HierarchyMetricXML{
+			Name:     metric,
+			Children: strings.Join(children, " "),
+		})
+	}
+	return xmlResponse, nil
+}
+
+// isJSONResponse returns true where JSON has been requested via WithResponseFormat, unless backend
+// has indicated that it has responded with XML regardless
+func (c *Client) isJSONResponse(resp *http.Response) bool {
+	if c.options.responseFormat != JSON {
+		return false
+	}
+	return !strings.Contains(resp.Header.Get("Content-Type"), "xml")
+}
+
 // handleAuthExtensions handles known extensions
 // extensions must not be nil
 func (c *Client) handleAuthExtensions(xmlResp internal.AuthResponseXML, resp *http.Response, extensions api.Extensions) threescale.AuthorizeExtensions {
@@ -303,19 +1123,26 @@ func (c *Client) handleAuthExtensions(xmlResp internal.AuthResponseXML, resp *ht
 	return annotatedExts
 }
 
-func (c *Client) convertXmlUsageReports(xmlReports []internal.UsageReportXML) api.UsageReports {
+// convertXmlUsageReports converts the usage reports returned by backend, preserving the order in which
+// they were returned. Reports which cannot be converted are discarded, with the first error returned.
+func (c *Client) convertXmlUsageReports(xmlReports []internal.UsageReportXML) (api.UsageReports, error) {
 	if len(xmlReports) == 0 {
-		return nil
+		return nil, nil
 	}
+
+	var firstErr error
 	usageReports := make(api.UsageReports)
 	for _, report := range xmlReports {
-		if converted, err := convertXmlToUsageReport(report); err == nil {
-			//nothing we can do here if we hit an error besides continue
-			currentReports := usageReports[report.Metric]
-			usageReports[report.Metric] = append(currentReports, converted)
+		converted, err := convertXmlToUsageReport(report)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to convert usage report for metric %s - %s", report.Metric, err.Error())
+			}
+			continue
 		}
+		usageReports[report.Metric] = append(usageReports[report.Metric], converted)
 	}
-	return usageReports
+	return usageReports, firstErr
 }
 
 func (c *Client) convertXmlHierarchy(xmlHierarchy internal.HierarchyXML) api.Hierarchy {
@@ -385,43 +1212,91 @@ func (c *Client) parseRejectionReasonHeader(resp *http.Response) string {
 	return resp.Header.Get("3scale-Rejection-Reason")
 }
 
+// readBody reads the body of the response, returning an error rather than reading further
+// should the body exceed the maximum size configured for the Client
+func (c *Client) readBody(resp *http.Response) ([]byte, error) {
+	maxBytes := c.options.maxResponseBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxResponseBytes
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > maxBytes {
+		return nil, fmt.Errorf("response body exceeds the maximum size of %d bytes", maxBytes)
+	}
+	return body, nil
+}
+
+// do sends the request to backend, dumping the request and response where WithRequestResponseDump has been provided
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.options.dump == nil {
+		return c.getHTTPClient().Do(req)
+	}
+
+	var dump bytes.Buffer
+
+	// the request body is restored by DumpRequestOut so remains available to be sent
+	if reqDump, err := httputil.DumpRequestOut(req, true); err != nil {
+		fmt.Fprintf(&dump, "failed to dump request - %s\n", err.Error())
+	} else {
+		dump.Write(reqDump)
+	}
+
+	// the response body is read in full and replaced by DumpResponse so remains available to be decoded
+	resp, err := c.getHTTPClient().Do(req)
+	if err != nil {
+		fmt.Fprintf(&dump, "\nrequest failed - %s\n\n", err.Error())
+	} else if respDump, dumpErr := httputil.DumpResponse(resp, true); dumpErr != nil {
+		fmt.Fprintf(&dump, "\nfailed to dump response - %s\n\n", dumpErr.Error())
+	} else {
+		fmt.Fprintf(&dump, "\n%s\n\n", respDump)
+	}
+
+	c.dumpMutex.Lock()
+	c.options.dump.Write(dump.Bytes())
+	c.dumpMutex.Unlock()
+
+	return resp, err
+}
+
+// decodeErr wraps an error encountered while decoding a response body from backend, including the status code and
+// a snippet of the offending body to aid debugging
+func decodeErr(err error, statusCode int, body []byte) error {
+	snippet := string(body)
+	if len(snippet) > maxBodySnippet {
+		snippet = snippet[:maxBodySnippet] + "..."
+	}
+	return fmt.Errorf("%w - %s - status: %d - body: %q", threescale.ErrDecode, err.Error(), statusCode, snippet)
+}
+
+// backendError returns a BackendError for a response with a 5xx status code
+func backendError(resp *http.Response) error {
+	return &threescale.BackendError{StatusCode: resp.StatusCode, Status: resp.Status}
+}
+
+// isRedirect returns true for a 3xx response, which is only returned to the caller of the http.Client
+// where the redirect has not been followed
+func isRedirect(resp *http.Response) bool {
+	return resp.StatusCode >= 300 && resp.StatusCode <= 399
+}
+
+// redirectError returns an error for a redirect which has not been followed, rather than attempting to decode it
+func redirectError(resp *http.Response) error {
+	return fmt.Errorf("%w - status: %s, location: %s", threescale.ErrUnexpectedRedirect, resp.Status, resp.Header.Get("Location"))
+}
+
 func (c *Client) wrapError(err error) error {
-	return fmt.Errorf("%s - %s ", errHttpReq.Error(), err.Error())
+	return fmt.Errorf("%w - %s", threescale.ErrHTTPBuild, err.Error())
 }
 
 // CodeToStatusCode transforms a client response code to http status code.
-// See https://github.com/3scale/apisonator/blob/v2.96.2/docs/rfcs/error_responses.md
+// Returns 0 for unknown codes. See threescale.CodeToStatusCode
 func CodeToStatusCode(errorCode string) int {
-	transform := map[string]int{
-		"access_token_storage_error":             http.StatusBadRequest,
-		"not_valid_data":                         http.StatusBadRequest,
-		"bad_request":                            http.StatusBadRequest,
-		"access_token_already_exists":            http.StatusBadRequest,
-		"content_type_invalid":                   http.StatusBadRequest,
-		"provider_key_invalid":                   http.StatusForbidden,
-		"user_requires_registration":             http.StatusForbidden,
-		"user_key_invalid":                       http.StatusForbidden,
-		"authentication_error":                   http.StatusForbidden,
-		"provider_key_or_service_token_required": http.StatusForbidden,
-		"service_token_invalid":                  http.StatusForbidden,
-		"application_not_found":                  http.StatusNotFound,
-		"application_token_invalid":              http.StatusNotFound,
-		"service_id_invalid":                     http.StatusNotFound,
-		"metric_invalid":                         http.StatusNotFound,
-		"limits_exceeded":                        http.StatusConflict,
-		"oauth_not_enabled":                      http.StatusConflict,
-		"redirect_uri_invalid":                   http.StatusConflict,
-		"redirect_url_invalid":                   http.StatusConflict,
-		"application_not_active":                 http.StatusConflict,
-		"application_key_invalid":                http.StatusConflict,
-		"referrer_not_allowed":                   http.StatusConflict,
-		"application_has_inconsistent_data":      http.StatusUnprocessableEntity,
-		"referrer_filter_invalid":                http.StatusUnprocessableEntity,
-		"required_params_missing":                http.StatusUnprocessableEntity,
-		"usage_value_invalid":                    http.StatusUnprocessableEntity,
-		"service_id_missing":                     http.StatusUnprocessableEntity,
-	}[errorCode]
-	return transform
+	return threescale.CodeToStatusCode(errorCode)
 }
 
 type kind int
@@ -435,7 +1310,27 @@ const (
 	oauthAuthRep
 )
 
+// String returns the name of the endpoint called for the kind, as returned by EndpointFromContext
+func (k kind) String() string {
+	switch k {
+	case auth:
+		return "authorize"
+	case authRep:
+		return "authrep"
+	case report:
+		return "report"
+	case oauthAuth:
+		return "oauth_authorize"
+	case oauthAuthRep:
+		return "oauth_authrep"
+	default:
+		return "unknown"
+	}
+}
+
 // Verifies a custom backend is valid
+// verifyBackendUrl parses the provided URL, ensuring the scheme is supported and the port, if provided, is in range.
+// IPv6 literal hosts must be enclosed in square brackets, for example https://[::1]:443
 func verifyBackendUrl(urlToCheck string) (*url.URL, error) {
 	backendURL, err := url.ParseRequestURI(urlToCheck)
 	if err == nil {
@@ -444,14 +1339,140 @@ func verifyBackendUrl(urlToCheck string) (*url.URL, error) {
 			err = fmt.Errorf("unsupported scheme %s passed to backend", scheme)
 		}
 
+		if err == nil && strings.Contains(backendURL.Hostname(), ":") && !strings.HasPrefix(backendURL.Host, "[") {
+			err = fmt.Errorf("ambiguous host %s passed to backend - IPv6 hosts must be enclosed in square brackets", backendURL.Host)
+		}
+
+		if port := backendURL.Port(); err == nil && port != "" {
+			if p, convErr := strconv.Atoi(port); convErr != nil || p < 1 || p > 65535 {
+				err = fmt.Errorf("invalid port %s passed to backend - port must be in the range 1-65535", port)
+			}
+		}
 	}
 	return backendURL, err
 }
 
-func defaultHttpClient() *http.Client {
-	c := http.DefaultClient
-	c.Timeout = defaultTimeout
-	return c
+// decompressBody replaces the body of a gzip encoded response with a reader which decompresses it.
+// Since we set the Accept-Encoding header ourselves, the transport will not decompress transparently.
+func decompressBody(resp *http.Response) error {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return nil
+	}
+
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		if err == io.EOF {
+			// empty body, nothing to decompress
+			return nil
+		}
+		return fmt.Errorf("%w - %s", threescale.ErrDecode, err.Error())
+	}
+
+	resp.Body = &gzipBody{Reader: reader, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	return nil
+}
+
+// gzipBody decompresses the underlying body, closing both on Close
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (gb *gzipBody) Close() error {
+	gb.Reader.Close()
+	return gb.body.Close()
+}
+
+// redactURL returns the string form of the provided URL with user info removed and the values of
+// any query parameters which carry credentials replaced
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+
+	if redacted.RawQuery != "" {
+		query := redacted.Query()
+		for key := range query {
+			if contains(key, sensitiveQueryKeys) {
+				query.Set(key, redactedValue)
+			}
+		}
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
+
+func defaultHttpClient(options *ClientOptions) *http.Client {
+	return &http.Client{
+		Transport: defaultTransport(options),
+		Timeout:   defaultTimeout,
+	}
+}
+
+// defaultTransport returns the transport provided via options or otherwise a clone of the
+// default transport configured with the transport related options
+func defaultTransport(options *ClientOptions) http.RoundTripper {
+	if options.transport != nil {
+		return options.transport
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if options.minTLSVersion != 0 {
+		transport.TLSClientConfig = &tls.Config{MinVersion: options.minTLSVersion}
+	}
+
+	if len(options.dialOverrides) > 0 {
+		transport.DialContext = overrideDialContext(options.dialOverrides)
+	}
+
+	// the proxy is validated by NewClient, otherwise the default of using the environment is retained
+	if proxyURL, err := parseProxyURL(options.proxyURL); err == nil && proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return transport
+}
+
+// parseProxyURL parses the URL provided via WithProxy, returning nil where none has been provided
+func parseProxyURL(rawURL string) (*url.URL, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url %s - %s", rawURL, err.Error())
+	}
+
+	if proxyURL.Scheme == "" || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url %s - scheme and host must be provided", rawURL)
+	}
+	return proxyURL, nil
+}
+
+// overrideDialContext returns a DialContext func which dials the address configured for a host in overrides
+// in place of the requested address
+func overrideDialContext(overrides map[string]string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if override, ok := overrides[host]; ok {
+			addr = override
+			if _, _, err := net.SplitHostPort(override); err != nil {
+				addr = net.JoinHostPort(override, port)
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
 }
 
 func contains(key string, in []string) bool {
@@ -463,7 +1484,18 @@ func contains(key string, in []string) bool {
 	return false
 }
 
+const redactedValue = "REDACTED"
+
+// sensitiveQueryKeys lists the query parameters that carry credentials
+var sensitiveQueryKeys = []string{
+	string(api.ServiceToken),
+	string(api.ProviderKey),
+	"user_key",
+	"app_key",
+}
+
 var granularityMap = map[string]api.Period{
+	"second":   api.Second,
 	"minute":   api.Minute,
 	"hour":     api.Hour,
 	"day":      api.Day,
@@ -473,6 +1505,23 @@ var granularityMap = map[string]api.Period{
 	"eternity": api.Eternity,
 }
 
+// parseTimestamp parses the timestamp using the first of the known timeLayouts which succeeds,
+// returning the error for the expected layout should none succeed
+func parseTimestamp(timestamp string) (int64, error) {
+	var firstErr error
+	for _, layout := range timeLayouts {
+		t, err := time.Parse(layout, timestamp)
+		if err == nil {
+			return t.Unix(), nil
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return 0, firstErr
+}
+
 // convert an xml decoded response into a user friendly UsageReport
 func convertXmlToUsageReport(ur internal.UsageReportXML) (api.UsageReport, error) {
 	var err error
@@ -486,11 +1535,11 @@ func convertXmlToUsageReport(ur internal.UsageReportXML) (api.UsageReport, error
 	}
 
 	parseTime := func(timestamp string) (int64, error) {
-		t, err := time.Parse(timeLayout, timestamp)
-		if err != nil {
-			return 0, err
+		// eternity windows are unbounded so backend does not provide the start and end
+		if timestamp == "" && pw.Period == api.Eternity {
+			return 0, nil
 		}
-		return t.Unix(), nil
+		return parseTimestamp(timestamp)
 	}
 
 	if pw.Start, err = parseTime(ur.PeriodStart); err != nil {
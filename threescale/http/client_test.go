@@ -2,16 +2,24 @@ package http
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"path/filepath"
 	"reflect"
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 	"unsafe"
@@ -19,6 +27,8 @@ import (
 	"github.com/3scale/3scale-go-client/fake"
 	"github.com/3scale/3scale-go-client/threescale"
 	"github.com/3scale/3scale-go-client/threescale/api"
+	"github.com/3scale/3scale-go-client/threescale/internal"
+	"github.com/3scale/3scale-go-client/threescale/testutil"
 )
 
 func TestClient_Authorize(t *testing.T) {
@@ -30,17 +40,18 @@ func TestClient_Authorize(t *testing.T) {
 		transaction    api.Transaction
 		expectErr      bool
 		expectErrMsg   string
+		expectErrIs    error
 		extensions     api.Extensions
 		expectResponse *threescale.AuthorizeResult
 		client         *Client
 		injectClient   *http.Client
 	}{
 		{
-			name:         "Test expect failure bad url passed",
-			auth:         api.ClientAuth{Type: api.ProviderKey, Value: "any"},
-			transaction:  api.Transaction{Params: api.Params{AppID: "any"}},
-			expectErr:    true,
-			expectErrMsg: httpReqErrText,
+			name:        "Test expect failure bad url passed",
+			auth:        api.ClientAuth{Type: api.ProviderKey, Value: "any"},
+			transaction: api.Transaction{Params: api.Params{AppID: "any"}},
+			expectErr:   true,
+			expectErrIs: threescale.ErrHTTPBuild,
 			client: &Client{
 				backendHost: "/some/invalid/value%_",
 				baseURL:     "/some/invalid/value%_",
@@ -422,6 +433,9 @@ func TestClient_Authorize(t *testing.T) {
 				if !strings.Contains(err.Error(), input.expectErrMsg) {
 					t.Errorf("expected our error message to contain substring %s", input.expectErrMsg)
 				}
+				if input.expectErrIs != nil && !errors.Is(err, input.expectErrIs) {
+					t.Errorf("expected error to match %v but got %v", input.expectErrIs, err)
+				}
 				return
 			}
 
@@ -437,8 +451,8 @@ func TestClient_AuthorizeWithOptions(t *testing.T) {
 	const svcID = "test"
 
 	// used for testing context option
-	ctx := context.Background()
-	ctx, _ = context.WithDeadline(ctx, time.Now())
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
 	// used for testing instrumentation hook
 	done := make(chan bool)
 
@@ -529,6 +543,96 @@ func TestClient_AuthorizeWithOptions(t *testing.T) {
 
 // because auth and auth rep essentially follow the same pattern, we can minimise the test in this instance
 // ensure our query param is correct and we are calling the correct endpoint
+func TestClient_AuthorizeWithFallback(t *testing.T) {
+	primary := api.ClientAuth{Type: api.ServiceToken, Value: "rotated"}
+	fallback := api.ClientAuth{Type: api.ProviderKey, Value: "pk"}
+
+	var calls []string
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		query := req.URL.Query()
+		if token := query.Get(string(api.ServiceToken)); token != "" {
+			calls = append(calls, token)
+			return &http.Response{
+				StatusCode: http.StatusForbidden,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GenInvalidIdOrTokenResp(token, "svc"))),
+				Header:     make(http.Header),
+			}
+		}
+
+		calls = append(calls, query.Get(string(api.ProviderKey)))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GetAuthSuccess())),
+			Header:     make(http.Header),
+		}
+	}))
+
+	request := threescale.Request{
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}}},
+	}
+
+	result, err := c.AuthorizeWithFallback(primary, fallback, request)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, result.Authorized)
+	equals(t, []string{"rotated", "pk"}, calls)
+
+	// the fallback is not used when the primary auth is accepted
+	calls = nil
+	result, err = c.AuthorizeWithFallback(fallback, primary, request)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, result.Authorized)
+	equals(t, []string{"pk"}, calls)
+}
+
+func TestClient_AuthorizeWithAppKeys(t *testing.T) {
+	var attempted []string
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		key := req.URL.Query().Get("app_key")
+		attempted = append(attempted, key)
+		if key != "valid" {
+			return testutil.NewResponse(http.StatusForbidden).
+				WithXML(fake.NewErrorResp("application_key_invalid", fmt.Sprintf(`application key "%s" is invalid`, key))).
+				Build()
+		}
+		return testutil.NewResponse(http.StatusOK).WithXML(fake.GetAuthSuccess()).Build()
+	}))
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app", AppKey: "rotated, valid, other"}}},
+	}
+
+	result, key, err := c.AuthorizeWithAppKeys(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, result.Authorized)
+	equals(t, "valid", key)
+	equals(t, []string{"rotated", "valid"}, attempted)
+	equals(t, "rotated, valid, other", apiCall.Transactions[0].Params.AppKey)
+
+	attempted = nil
+	apiCall.Transactions[0].Params.AppKey = "rotated,revoked"
+	result, key, err = c.AuthorizeWithAppKeys(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, false, result.Authorized)
+	equals(t, "application_key_invalid", result.ErrorCode)
+	equals(t, "revoked", key)
+	equals(t, []string{"rotated", "revoked"}, attempted)
+
+	if _, _, err := c.AuthorizeWithAppKeys(threescale.Request{}); !errors.Is(err, threescale.ErrValidation) {
+		t.Errorf("expected validation error but got %v", err)
+	}
+}
+
 func TestClient_AuthRep(t *testing.T) {
 	const svcID = "test"
 	type input struct {
@@ -538,6 +642,7 @@ func TestClient_AuthRep(t *testing.T) {
 		extensions     api.Extensions
 		expectErr      bool
 		expectErrMsg   string
+		expectErrIs    error
 		expectResponse *threescale.AuthorizeResult
 		client         *Client
 		injectClient   *http.Client
@@ -545,11 +650,11 @@ func TestClient_AuthRep(t *testing.T) {
 
 	inputs := []input{
 		{
-			name:         "Test expect failure bad url passed",
-			auth:         api.ClientAuth{Type: api.ProviderKey, Value: "any"},
-			transaction:  api.Transaction{Params: api.Params{AppID: "any"}},
-			expectErr:    true,
-			expectErrMsg: httpReqErrText,
+			name:        "Test expect failure bad url passed",
+			auth:        api.ClientAuth{Type: api.ProviderKey, Value: "any"},
+			transaction: api.Transaction{Params: api.Params{AppID: "any"}},
+			expectErr:   true,
+			expectErrIs: threescale.ErrHTTPBuild,
 			client: &Client{
 				backendHost: "/some/invalid/value%_",
 				baseURL:     "/some/invalid/value%_",
@@ -612,6 +717,9 @@ func TestClient_AuthRep(t *testing.T) {
 			if !strings.Contains(err.Error(), fixture.expectErrMsg) {
 				t.Errorf("expected our error message to contain substring %s", fixture.expectErrMsg)
 			}
+			if fixture.expectErrIs != nil && !errors.Is(err, fixture.expectErrIs) {
+				t.Errorf("expected error to match %v but got %v", fixture.expectErrIs, err)
+			}
 			return
 		}
 		equals(t, fixture.expectResponse, resp)
@@ -619,6 +727,117 @@ func TestClient_AuthRep(t *testing.T) {
 
 }
 
+func TestClient_SingleTransactionHelpers(t *testing.T) {
+	var endpoints []string
+	var params []url.Values
+	httpClient := NewTestClient(func(req *http.Request) *http.Response {
+		endpoints = append(endpoints, req.URL.Path)
+		params = append(params, req.URL.Query())
+		return testutil.NewResponse(http.StatusOK).WithXML(fake.GetAuthSuccess()).Build()
+	})
+	c := threeScaleTestClient(t, httpClient)
+
+	auth := api.ClientAuth{Type: api.ServiceToken, Value: "st"}
+	metrics := api.Metrics{"hits": 1}
+
+	calls := []func() (*threescale.AuthorizeResult, error){
+		func() (*threescale.AuthorizeResult, error) {
+			return c.AuthorizeUserKey("svc", "key", auth, metrics)
+		},
+		func() (*threescale.AuthorizeResult, error) {
+			return c.AuthorizeAppID("svc", "app", "secret", auth, metrics)
+		},
+		func() (*threescale.AuthorizeResult, error) {
+			return c.AuthRepUserKey("svc", "key", auth, metrics)
+		},
+		func() (*threescale.AuthorizeResult, error) {
+			return c.AuthRepAppID("svc", "app", "", auth, metrics)
+		},
+	}
+
+	for _, call := range calls {
+		result, err := call()
+		if err != nil {
+			t.Fatalf("unexpected error - %s", err.Error())
+		}
+		equals(t, true, result.Authorized)
+	}
+
+	equals(t, []string{authzEndpoint, authzEndpoint, authRepEndpoint, authRepEndpoint}, endpoints)
+
+	expect := []map[string]string{
+		{"user_key": "key", "app_id": "", "app_key": ""},
+		{"user_key": "", "app_id": "app", "app_key": "secret"},
+		{"user_key": "key", "app_id": "", "app_key": ""},
+		{"user_key": "", "app_id": "app", "app_key": ""},
+	}
+	for i, values := range params {
+		equals(t, "svc", values.Get(serviceIDKey))
+		equals(t, "st", values.Get("service_token"))
+		equals(t, "1", values.Get("usage[hits]"))
+		for key, value := range expect[i] {
+			equals(t, value, values.Get(key))
+		}
+	}
+}
+
+func TestClient_AuthRepTimestamp(t *testing.T) {
+	const timestamp = int64(1583839891)
+
+	var expectTimestamp string
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, expectTimestamp, req.URL.Query().Get("timestamp"))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GetAuthSuccess())),
+			Header:     make(http.Header),
+		}
+	}))
+
+	apiCall := threescale.Request{
+		Auth:    api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service: "svc",
+		Transactions: []api.Transaction{
+			{
+				Params:    api.Params{UserKey: "key"},
+				Metrics:   api.Metrics{"hits": 1},
+				Timestamp: timestamp,
+			},
+		},
+	}
+
+	expectTimestamp = threescale.FormatTimestamp(timestamp)
+	if _, err := c.AuthRep(apiCall); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	// oauth authrep records usage so, as for authrep, the timestamp is sent
+	if _, err := c.OauthAuthRep(apiCall); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	// authorize does not record usage so the timestamp is not sent
+	expectTimestamp = ""
+	if _, err := c.Authorize(apiCall); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	expectTimestamp = threescale.FormatTimestamp(timestamp)
+	if _, err := c.AuthorizeWithOptions(apiCall, WithAuthorizeTimestamp()); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	apiCall.Transactions[0].Timestamp = 0
+	expectTimestamp = ""
+	if _, err := c.AuthRep(apiCall); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	if _, err := c.AuthorizeWithOptions(apiCall, WithAuthorizeTimestamp()); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+}
+
 func TestClient_Report(t *testing.T) {
 	const svcID = "test-id"
 
@@ -628,7 +847,9 @@ func TestClient_Report(t *testing.T) {
 		transactions   []api.Transaction
 		expectErr      bool
 		expectErrMsg   string
+		expectErrIs    error
 		expectResponse *threescale.ReportResult
+		extensions     api.Extensions
 		client         *Client
 		injectClient   *http.Client
 	}{
@@ -637,7 +858,7 @@ func TestClient_Report(t *testing.T) {
 			auth:         api.ClientAuth{Type: api.ProviderKey, Value: "any"},
 			transactions: []api.Transaction{{Params: api.Params{AppID: "any"}}},
 			expectErr:    true,
-			expectErrMsg: httpReqErrText,
+			expectErrIs:  threescale.ErrHTTPBuild,
 			client: &Client{
 				backendHost: "/some/invalid/value%_",
 				baseURL:     "/some/invalid/value%_",
@@ -688,8 +909,9 @@ func TestClient_Report(t *testing.T) {
 				},
 			},
 			expectResponse: &threescale.ReportResult{
-				Accepted:  false,
-				ErrorCode: "user_key_invalid",
+				Accepted:        false,
+				ErrorCode:       "user_key_invalid",
+				RejectionReason: `user key "any" is invalid`,
 			},
 			injectClient: NewTestClient(func(req *http.Request) *http.Response {
 				return &http.Response{
@@ -699,6 +921,25 @@ func TestClient_Report(t *testing.T) {
 				}
 			}),
 		},
+		{
+			name:         "Test expect failure 403 with no_body extension",
+			auth:         api.ClientAuth{Type: api.ProviderKey, Value: "any"},
+			transactions: []api.Transaction{{Params: api.Params{UserKey: "any"}}},
+			extensions:   api.Extensions{NoBodyExtension: "1", RejectionReasonHeaderExtension: "1"},
+			expectResponse: &threescale.ReportResult{
+				Accepted:  false,
+				ErrorCode: "user_key_invalid",
+			},
+			injectClient: NewTestClient(func(req *http.Request) *http.Response {
+				header := make(http.Header)
+				header.Set("3scale-Rejection-Reason", "user_key_invalid")
+				return &http.Response{
+					StatusCode: http.StatusForbidden,
+					Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+					Header:     header,
+				}
+			}),
+		},
 		{
 			name: "Test params formatting",
 			auth: api.ClientAuth{
@@ -728,7 +969,56 @@ func TestClient_Report(t *testing.T) {
 				// we know that Encode will sort by keys so we can predict this output
 				// decoded to service_id=test-id&service_token=st&transactions[0][timestamp]=500&transactions[0][usage][hits]=1&transactions[0][user_key]=test&transactions[1][timestamp]=1000&transactions[1][usage][hits]=1&transactions[1][usage][other]=2&transactions[1][user_key]=test-2
 				expect := `service_id=test-id&service_token=st&transactions%5B0%5D%5Btimestamp%5D=500&transactions%5B0%5D%5Busage%5D%5Bhits%5D=1&transactions%5B0%5D%5Buser_key%5D=test&transactions%5B1%5D%5Btimestamp%5D=1000&transactions%5B1%5D%5Busage%5D%5Bhits%5D=1&transactions%5B1%5D%5Busage%5D%5Bother%5D=2&transactions%5B1%5D%5Buser_key%5D=test-2`
-				equals(t, expect, req.URL.RawQuery)
+				body, err := ioutil.ReadAll(req.Body)
+				if err != nil {
+					t.Error("unexpected error reading request body")
+				}
+				equals(t, expect, string(body))
+				equals(t, "", req.URL.RawQuery)
+				equals(t, int64(len(expect)), req.ContentLength)
+				equals(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+
+				return &http.Response{
+					StatusCode: 202,
+					Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+					Header:     make(http.Header),
+				}
+			}),
+		},
+		{
+			name: "Test per transaction app_key is emitted",
+			auth: api.ClientAuth{
+				Type:  api.ServiceToken,
+				Value: "st",
+			},
+			transactions: []api.Transaction{
+				{
+					Params: api.Params{
+						AppID:  "app-1",
+						AppKey: "key-1",
+					},
+					Metrics: api.Metrics{"hits": 1},
+				},
+				{
+					Params: api.Params{
+						AppID:  "app-2",
+						AppKey: "key-2",
+					},
+					Metrics: api.Metrics{"hits": 2},
+				},
+			},
+			expectResponse: &threescale.ReportResult{
+				Accepted: true,
+			},
+			injectClient: NewTestClient(func(req *http.Request) *http.Response {
+				if err := req.ParseForm(); err != nil {
+					t.Error("unexpected error parsing request body")
+				}
+				values := req.PostForm
+				equals(t, "key-1", values.Get("transactions[0][app_key]"))
+				equals(t, "key-2", values.Get("transactions[1][app_key]"))
+				equals(t, "app-1", values.Get("transactions[0][app_id]"))
+				equals(t, "app-2", values.Get("transactions[1][app_id]"))
 
 				return &http.Response{
 					StatusCode: 202,
@@ -738,9 +1028,10 @@ func TestClient_Report(t *testing.T) {
 			}),
 		},
 		{
-			name:      "Test 500+ status codes return an error",
-			auth:      api.ClientAuth{Type: api.ProviderKey, Value: "any"},
-			expectErr: true,
+			name:         "Test 500+ status codes return an error",
+			auth:         api.ClientAuth{Type: api.ProviderKey, Value: "any"},
+			transactions: []api.Transaction{{Params: api.Params{AppID: "any"}}},
+			expectErr:    true,
 			injectClient: NewTestClient(func(req *http.Request) *http.Response {
 				equals(t, req.URL.Path, reportEndpoint)
 				return &http.Response{
@@ -770,6 +1061,7 @@ func TestClient_Report(t *testing.T) {
 
 			apiCall := threescale.Request{
 				Auth:         input.auth,
+				Extensions:   input.extensions,
 				Service:      svcID,
 				Transactions: input.transactions,
 			}
@@ -783,8 +1075,12 @@ func TestClient_Report(t *testing.T) {
 				if !strings.Contains(err.Error(), input.expectErrMsg) {
 					t.Errorf("expected our error message to contain substring %s", input.expectErrMsg)
 				}
+				if input.expectErrIs != nil && !errors.Is(err, input.expectErrIs) {
+					t.Errorf("expected error to match %v but got %v", input.expectErrIs, err)
+				}
 				return
 			}
+			equals(t, input.expectResponse.RejectionReason, resp.RejectionReason)
 			equals(t, input.expectResponse.ErrorCode, resp.ErrorCode)
 			equals(t, input.expectResponse.Accepted, resp.Accepted)
 		})
@@ -794,8 +1090,8 @@ func TestClient_Report(t *testing.T) {
 func TestClient_ReportWithOptions(t *testing.T) {
 	const svcID = "test-id"
 
-	ctx := context.Background()
-	ctx, _ = context.WithDeadline(ctx, time.Now())
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
 	// used for testing instrumentation hook
 	done := make(chan bool)
 
@@ -893,94 +1189,2438 @@ func TestClient_ReportWithOptions(t *testing.T) {
 	}
 }
 
-func TestClient_GetVersion(t *testing.T) {
-	// expect err on simulate network err
-	c := &Client{
-		httpClient: &http.Client{
-			Transport:     nil,
-			CheckRedirect: nil,
-			Jar:           nil,
-			Timeout:       time.Nanosecond,
-		},
-	}
-	_, err := c.GetVersion()
-	if err == nil {
-		t.Error("expected network err caused by timeout exceeded")
-	}
+func TestClient_ReportBatching(t *testing.T) {
+	const svcID = "test-id"
 
-	// expect err on badly configured client
-	c = &Client{
-		backendHost: "/some/invalid/value%_",
-		baseURL:     "/some/invalid/value%_",
-		httpClient: &http.Client{
-			Transport:     nil,
-			CheckRedirect: nil,
-			Jar:           nil,
-			Timeout:       time.Nanosecond,
-		},
+	transactions := make([]api.Transaction, 5)
+	for i := range transactions {
+		transactions[i] = api.Transaction{
+			Params:  api.Params{UserKey: fmt.Sprintf("key-%d", i)},
+			Metrics: api.Metrics{"hits": 1},
+		}
 	}
-	_, err = c.GetVersion()
-	if err == nil {
-		t.Error("expected err building request")
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      svcID,
+		Transactions: transactions,
 	}
 
-	// expect err on decode err
-	httpClientTriggerDecodeErr := NewTestClient(func(req *http.Request) *http.Response {
-		equals(t, req.URL.Path, statusEndpoint)
-		resp := `not-json`
-		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(resp))}
+	t.Run("Test transactions are split into chunks", func(t *testing.T) {
+		var reported []string
+		var requests int
+		c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+			requests++
+			if err := req.ParseForm(); err != nil {
+				t.Error("unexpected error parsing request body")
+			}
+			values := req.PostForm
+			for i := 0; i < 2; i++ {
+				if key := values.Get(fmt.Sprintf("transactions[%d][user_key]", i)); key != "" {
+					reported = append(reported, key)
+				}
+			}
+			if values.Get("transactions[2][user_key]") != "" {
+				t.Error("unexpected transaction exceeding batch size")
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+				Header:     make(http.Header),
+			}
+		}))
+
+		resp, err := c.ReportWithOptions(apiCall, WithMaxBatchSize(2))
+		if err != nil {
+			t.Errorf("unexpected error - %s", err.Error())
+		}
+		equals(t, true, resp.Accepted)
+		equals(t, 3, requests)
+		equals(t, []string{"key-0", "key-1", "key-2", "key-3", "key-4"}, reported)
+		equals(t, []int{0, 1, 2, 3, 4}, resp.AcceptedIndexes)
 	})
-	c = threeScaleTestClient(t, httpClientTriggerDecodeErr)
-	_, err = c.GetVersion()
-	if err == nil {
-		t.Error("expected err decoding json")
+
+	t.Run("Test failing chunk is identified", func(t *testing.T) {
+		var requests int
+		c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+			requests++
+			if requests == 2 {
+				return &http.Response{
+					StatusCode: http.StatusForbidden,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GenInvalidUserKey("key-2"))),
+					Header:     make(http.Header),
+				}
+			}
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+				Header:     make(http.Header),
+			}
+		}))
+
+		resp, err := c.ReportWithOptions(apiCall, WithMaxBatchSize(2))
+		if err == nil {
+			t.Fatal("expected error on partial failure")
+		}
+		if !strings.Contains(err.Error(), "chunk 1") {
+			t.Errorf("expected error to identify failing chunk but got %s", err.Error())
+		}
+		equals(t, false, resp.Accepted)
+		equals(t, "user_key_invalid", resp.ErrorCode)
+		equals(t, 2, resp.PartialCount)
+		equals(t, []int{0, 1}, resp.AcceptedIndexes)
+		equals(t, 2, requests)
+	})
+
+	t.Run("Test limits exceeded by a chunk is a denial", func(t *testing.T) {
+		limitExceeded := testutil.NewResponse(http.StatusConflict).
+			WithXML(fake.NewErrorResp("limits_exceeded", "usage limits are exceeded"))
+
+		var requests int
+		c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+			requests++
+			if requests == 2 {
+				return limitExceeded.Build()
+			}
+			return testutil.NewResponse(http.StatusAccepted).Build()
+		}))
+
+		// a report sent in chunks must behave as a report sent in a single request
+		single, err := threeScaleTestClient(t, NewTestClient(testutil.RespondWith(limitExceeded))).Report(apiCall)
+		if err != nil {
+			t.Fatalf("unexpected error - %s", err.Error())
+		}
+		equals(t, true, single.IsLimitExceeded())
+
+		resp, err := c.ReportWithOptions(apiCall, WithMaxBatchSize(2))
+		if err != nil {
+			t.Fatalf("unexpected error - %s", err.Error())
+		}
+		equals(t, true, resp.IsLimitExceeded())
+		equals(t, 2, resp.PartialCount)
+		equals(t, []int{0, 1}, resp.AcceptedIndexes)
+		equals(t, 2, requests)
+	})
+
+	t.Run("Test cancellation stops further chunks", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var requests int
+		c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+			requests++
+			// the caller gives up while the first chunk is in flight
+			cancel()
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+				Header:     make(http.Header),
+			}
+		}))
+
+		resp, err := c.ReportWithOptions(apiCall, WithMaxBatchSize(2), WithContext(ctx))
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context error but got %v", err)
+		}
+		equals(t, false, resp.Accepted)
+		equals(t, 2, resp.PartialCount)
+		equals(t, []int{0, 1}, resp.AcceptedIndexes)
+		equals(t, 1, requests)
+	})
+
+	t.Run("Test default batch size sends a single request", func(t *testing.T) {
+		var requests int
+		c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+			requests++
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+				Header:     make(http.Header),
+			}
+		}))
+
+		resp, err := c.Report(apiCall)
+		if err != nil {
+			t.Errorf("unexpected error - %s", err.Error())
+		}
+		equals(t, true, resp.Accepted)
+		equals(t, []int{0, 1, 2, 3, 4}, resp.AcceptedIndexes)
+		equals(t, 1, requests)
+	})
+
+	t.Run("Test rejected report has no accepted indexes", func(t *testing.T) {
+		c := threeScaleTestClient(t, NewTestClient(testutil.RespondWith(
+			testutil.NewResponse(http.StatusForbidden).WithXML(fake.GenInvalidUserKey("key-0")),
+		)))
+
+		resp, err := c.Report(apiCall)
+		if err != nil {
+			t.Errorf("unexpected error - %s", err.Error())
+		}
+		equals(t, false, resp.Accepted)
+		equals(t, []int(nil), resp.AcceptedIndexes)
+	})
+}
+
+func TestClient_MetricNameEncoding(t *testing.T) {
+	metrics := api.Metrics{"my.metric": 1, "my-metric": 2, "métrica_日本": 3}
+	expect := url.Values{
+		"usage[my.metric]":  []string{"1"},
+		"usage[my-metric]":  []string{"2"},
+		"usage[métrica_日本]": []string{"3"},
 	}
 
-	// happy path
-	httpClientSuccess := NewTestClient(func(req *http.Request) *http.Response {
-		equals(t, req.URL.Path, statusEndpoint)
-		resp := `{"status":"ok","version":{"backend":"2.96.2"}}`
-		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(resp))}
+	var query, body string
+	httpClient := NewTestClient(func(req *http.Request) *http.Response {
+		if req.Method == http.MethodPost {
+			b, _ := ioutil.ReadAll(req.Body)
+			body = string(b)
+			return testutil.NewResponse(http.StatusAccepted).Build()
+		}
+		query = req.URL.RawQuery
+		return testutil.NewResponse(http.StatusOK).WithXML(fake.GetAuthSuccess()).Build()
 	})
+	c := threeScaleTestClient(t, httpClient)
 
-	c = threeScaleTestClient(t, httpClientSuccess)
-	version, err := c.GetVersion()
-	if err != nil {
-		t.Error("unexpected error calling GetVersion()")
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}, Metrics: metrics}},
 	}
 
-	if version != "2.96.2" {
-		t.Error("unexpected result calling GetVersion()")
+	if _, err := c.Authorize(apiCall); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
 	}
 
-}
+	if _, err := c.Report(apiCall); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
 
-func TestNewClient(t *testing.T) {
-	_, err := NewClient("ftp://invalid.com", http.DefaultClient)
-	if err == nil {
-		t.Error("expected error for invalid scheme")
+	// brackets and non-ascii characters must be percent encoded, leaving '.' and '-' untouched
+	for _, encoded := range []string{"usage%5Bmy.metric%5D=1", "usage%5Bmy-metric%5D=2", "usage%5Bm%C3%A9trica_%E6%97%A5%E6%9C%AC%5D=3"} {
+		if !strings.Contains(query, encoded) {
+			t.Errorf("expected query %s to contain %s", query, encoded)
+		}
 	}
 
-	c, err := NewClient(defaultBackendUrl, http.DefaultClient)
+	values, err := url.ParseQuery(query)
 	if err != nil {
-		t.Error("unexpected error when creating client")
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	for k, v := range expect {
+		equals(t, v, values[k])
 	}
 
-	if c.GetPeer() != "su1.3scale.net" {
-		t.Error("unexpected hostname set via constructor")
+	values, err = url.ParseQuery(body)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	for k, v := range expect {
+		equals(t, v, values[strings.Replace(k, "usage", "transactions[0][usage]", 1)])
 	}
 }
 
-func TestNewDefaultClient(t *testing.T) {
-	c, _ := NewDefaultClient()
+func TestClient_RequestURL(t *testing.T) {
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		if req.Method == http.MethodPost {
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+				Header:     make(http.Header),
+			}
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GetAuthSuccess())),
+			Header:     make(http.Header),
+		}
+	}))
 
-	if c.baseURL != defaultBackendUrl {
-		t.Error("unexpected setting in default client")
+	apiCall := threescale.Request{
+		Auth:    api.ClientAuth{Type: api.ServiceToken, Value: "secret-token"},
+		Service: "svc",
+		Transactions: []api.Transaction{
+			{Params: api.Params{AppID: "app", AppKey: "secret-key"}},
+		},
 	}
 
-	if c.httpClient.Timeout != defaultTimeout {
-		t.Error("unexpected setting in default client")
+	authResult, err := c.Authorize(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, "https://su1.3scale.net:443/transactions/authorize.xml?app_id=app&app_key=REDACTED&service_id=svc&service_token=REDACTED", authResult.RequestURL)
+
+	reportResult, err := c.Report(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, "https://su1.3scale.net:443/transactions.xml", reportResult.RequestURL)
+}
+
+func TestClient_Latency(t *testing.T) {
+	const delay = 10 * time.Millisecond
+
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		time.Sleep(delay)
+		if req.Method == http.MethodPost {
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+				Header:     make(http.Header),
+			}
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GetAuthSuccess())),
+			Header:     make(http.Header),
+		}
+	}))
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	authResult, err := c.Authorize(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	if authResult.Latency < delay {
+		t.Errorf("expected latency of at least %s but got %s", delay, authResult.Latency)
+	}
+
+	reportResult, err := c.Report(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	if reportResult.Latency < delay {
+		t.Errorf("expected latency of at least %s but got %s", delay, reportResult.Latency)
+	}
+}
+
+func TestWithReportOnlyPositiveDeltas(t *testing.T) {
+	// simulates deltas computed across a period reset where the current usage is lower than previously observed
+	apiCall := threescale.Request{
+		Auth:    api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service: "svc",
+		Transactions: []api.Transaction{
+			{
+				Params:  api.Params{UserKey: "key"},
+				Metrics: api.Metrics{"hits": 5, "reset": -3, "unchanged": 0},
+			},
+		},
+	}
+
+	reportClient := func(expect string) *http.Client {
+		return NewTestClient(func(req *http.Request) *http.Response {
+			if err := req.ParseForm(); err != nil {
+				t.Error("unexpected error parsing request body")
+			}
+			equals(t, expect, req.PostForm.Encode())
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+				Header:     make(http.Header),
+			}
+		})
+	}
+
+	// decodes to service_id=svc&service_token=st&transactions[0][usage][hits]=5&transactions[0][user_key]=key
+	expectFiltered := `service_id=svc&service_token=st&transactions%5B0%5D%5Busage%5D%5Bhits%5D=5&transactions%5B0%5D%5Buser_key%5D=key`
+	c, _ := NewClient(defaultBackendUrl, reportClient(expectFiltered), WithReportOnlyPositiveDeltas())
+	if _, err := c.Report(apiCall); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	// decodes to service_id=svc&service_token=st&transactions[0][usage][hits]=5&transactions[0][usage][reset]=-3&transactions[0][usage][unchanged]=0&transactions[0][user_key]=key
+	expectUnfiltered := `service_id=svc&service_token=st&transactions%5B0%5D%5Busage%5D%5Bhits%5D=5&transactions%5B0%5D%5Busage%5D%5Breset%5D=-3&transactions%5B0%5D%5Busage%5D%5Bunchanged%5D=0&transactions%5B0%5D%5Buser_key%5D=key`
+	c, _ = NewClient(defaultBackendUrl, reportClient(expectUnfiltered), WithIncludeZeroMetrics())
+	if _, err := c.Report(apiCall); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	c, _ = NewClient(defaultBackendUrl, NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, "service_id=svc&service_token=st&usage%5Bhits%5D=5&user_key=key", req.URL.RawQuery)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GetAuthSuccess())),
+			Header:     make(http.Header),
+		}
+	}), WithReportOnlyPositiveDeltas())
+	if _, err := c.AuthRep(apiCall); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+}
+
+func TestClient_ReportLog(t *testing.T) {
+	log := api.Log{Request: "GET /orders?id=1&expand=true", Response: `{"id": 1}`, Code: http.StatusOK}
+	apiCall := threescale.Request{
+		Auth:    api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service: "svc",
+		Transactions: []api.Transaction{
+			{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"hits": 1}},
+			{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"hits": 1}, Log: log},
+		},
+	}
+
+	var values []url.Values
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		if req.Method == http.MethodPost {
+			body, _ := ioutil.ReadAll(req.Body)
+			// the log values are escaped rather than corrupting the encoded body
+			if !strings.Contains(string(body), url.QueryEscape("transactions[1][log][request]")+"="+url.QueryEscape(log.Request)) {
+				t.Errorf("expected escaped log in body but got %s", body)
+			}
+			parsed, _ := url.ParseQuery(string(body))
+			values = append(values, parsed)
+			return testutil.NewResponse(http.StatusAccepted).Build()
+		}
+		values = append(values, req.URL.Query())
+		return testutil.NewResponse(http.StatusOK).WithXML(fake.GetAuthSuccess()).Build()
+	}))
+
+	if _, err := c.Report(apiCall); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	reported := values[0]
+	equals(t, log.Request, reported.Get("transactions[1][log][request]"))
+	equals(t, log.Response, reported.Get("transactions[1][log][response]"))
+	equals(t, "200", reported.Get("transactions[1][log][code]"))
+	for key := range reported {
+		if strings.HasPrefix(key, "transactions[0][log]") {
+			t.Errorf("unexpected log for transaction without a log - %s", key)
+		}
+	}
+
+	apiCall.Transactions = apiCall.Transactions[1:]
+	if _, err := c.AuthRep(apiCall); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, log.Request, values[1].Get("log[request]"))
+	equals(t, log.Response, values[1].Get("log[response]"))
+	equals(t, "200", values[1].Get("log[code]"))
+
+	// authorize does not record usage so the log is not sent
+	if _, err := c.Authorize(apiCall); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, "", values[2].Get("log[request]"))
+}
+
+func TestClient_ReportCorrection(t *testing.T) {
+	metrics := api.Metrics{}
+	if err := metrics.SetRaw("hits", -5); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}, Metrics: metrics}},
+	}
+
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		if err := req.ParseForm(); err != nil {
+			t.Error("unexpected error parsing request body")
+		}
+		equals(t, "-5", req.PostForm.Get("transactions[0][usage][hits]"))
+		return testutil.NewResponse(http.StatusAccepted).Build()
+	}))
+
+	result, err := c.Report(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, result.Accepted)
+}
+
+func TestWithIncludeZeroMetrics(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:    api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service: "svc",
+		Transactions: []api.Transaction{
+			{
+				Params:  api.Params{UserKey: "key"},
+				Metrics: api.Metrics{"hits": 1, "unchanged": 0},
+			},
+		},
+	}
+
+	authClient := func(expect string) *http.Client {
+		return NewTestClient(func(req *http.Request) *http.Response {
+			equals(t, expect, req.URL.RawQuery)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GetAuthSuccess())),
+				Header:     make(http.Header),
+			}
+		})
+	}
+
+	// zero valued metrics are omitted by default
+	c, _ := NewClient(defaultBackendUrl, authClient("service_id=svc&service_token=st&usage%5Bhits%5D=1&user_key=key"))
+	if _, err := c.Authorize(apiCall); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	c, _ = NewClient(
+		defaultBackendUrl,
+		authClient("service_id=svc&service_token=st&usage%5Bhits%5D=1&usage%5Bunchanged%5D=0&user_key=key"),
+		WithIncludeZeroMetrics(),
+	)
+	if _, err := c.Authorize(apiCall); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+}
+
+func TestWithRejectionReasonHeader(t *testing.T) {
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		header := make(http.Header)
+		// simulates backend which only sets the header when the extension has been requested
+		if strings.Contains(req.Header.Get(enableExtensions), "rejection_reason_header=1") {
+			header.Set("3scale-Rejection-Reason", "limits_exceeded")
+		}
+		return &http.Response{
+			StatusCode: http.StatusConflict,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GetLimitExceededResp())),
+			Header:     header,
+		}
+	}))
+
+	request := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Extensions:   api.Extensions{api.HierarchyExtension: "1"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}}},
+	}
+
+	result, err := c.AuthorizeWithOptions(request)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, "", result.ErrorCode)
+
+	result, err = c.AuthorizeWithOptions(request, WithRejectionReasonHeader())
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, "limits_exceeded", result.ErrorCode)
+	equals(t, api.Extensions{api.HierarchyExtension: "1"}, request.Extensions)
+}
+
+func TestWithSortedUsageReports(t *testing.T) {
+	start := time.Unix(1550844000, 0).UTC()
+	resp := fake.NewAuthSuccess().
+		WithUsageReportWindow("hits", "hour", start, start.Add(time.Hour), 100, 10).
+		WithUsageReportWindow("hits", "minute", start, start.Add(time.Minute), 10, 1).
+		XML()
+
+	c := threeScaleTestClient(t, NewTestClient(testutil.RespondWith(testutil.NewResponse(http.StatusOK).WithXML(resp))))
+
+	request := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	periods := func(result *threescale.AuthorizeResult) []api.Period {
+		var got []api.Period
+		for _, report := range result.UsageReports["hits"] {
+			got = append(got, report.PeriodWindow.Period)
+		}
+		return got
+	}
+
+	inputs := []struct {
+		name    string
+		options []Option
+		expect  []api.Period
+	}{
+		{
+			name:   "Test backend order is preserved by default",
+			expect: []api.Period{api.Hour, api.Minute},
+		},
+		{
+			name:    "Test ascending granularity",
+			options: []Option{WithSortedUsageReports(true)},
+			expect:  []api.Period{api.Minute, api.Hour},
+		},
+		{
+			name:    "Test descending granularity",
+			options: []Option{WithSortedUsageReports(false)},
+			expect:  []api.Period{api.Hour, api.Minute},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			result, err := c.AuthRepWithOptions(request, input.options...)
+			if err != nil {
+				t.Fatalf("unexpected error - %s", err.Error())
+			}
+			equals(t, input.expect, periods(result))
+		})
+	}
+}
+
+func TestWithIdempotencyKey(t *testing.T) {
+	key, err := NewIdempotencyKey()
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	other, _ := NewIdempotencyKey()
+	if key == "" || key == other {
+		t.Errorf("expected unique keys but got %s and %s", key, other)
+	}
+
+	var keys []string
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		keys = append(keys, req.Header.Get("Idempotency-Key"))
+		return &http.Response{
+			StatusCode: http.StatusAccepted,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+			Header:     make(http.Header),
+		}
+	}))
+
+	request := threescale.Request{
+		Auth:    api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service: "svc",
+		Transactions: []api.Transaction{
+			{Params: api.Params{UserKey: "key-1"}, Metrics: api.Metrics{"hits": 1}},
+			{Params: api.Params{UserKey: "key-2"}, Metrics: api.Metrics{"hits": 1}},
+		},
+	}
+
+	// a retried report carries the same key
+	for i := 0; i < 2; i++ {
+		if _, err := c.ReportWithOptions(request, WithIdempotencyKey(key)); err != nil {
+			t.Errorf("unexpected error - %s", err.Error())
+		}
+	}
+	equals(t, []string{key, key}, keys)
+
+	keys = nil
+	if _, err := c.ReportWithOptions(request, WithIdempotencyKey(key), WithMaxBatchSize(1)); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+	equals(t, []string{key + "-0", key + "-1"}, keys)
+
+	keys = nil
+	if _, err := c.Report(request); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+	equals(t, []string{""}, keys)
+}
+
+func TestWithFlatUsageHierarchy(t *testing.T) {
+	transactions := []api.Transaction{
+		{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"child": 2}},
+		{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"hits": 1, "child": 3}},
+	}
+
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, "flat_usage=1", req.Header.Get(enableExtensions))
+
+		if err := req.ParseForm(); err != nil {
+			t.Error("unexpected error parsing request body")
+		}
+		values := req.PostForm
+		equals(t, "2", values.Get("transactions[0][usage][hits]"))
+		equals(t, "2", values.Get("transactions[0][usage][child]"))
+		equals(t, "4", values.Get("transactions[1][usage][hits]"))
+		equals(t, "3", values.Get("transactions[1][usage][child]"))
+
+		return &http.Response{
+			StatusCode: http.StatusAccepted,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+			Header:     make(http.Header),
+		}
+	}))
+
+	request := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: transactions,
+	}
+
+	result, err := c.ReportWithOptions(request, WithFlatUsageHierarchy(api.Hierarchy{"hits": []string{"child"}}))
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, result.Accepted)
+
+	// the callers request must be left untouched
+	equals(t, api.Metrics{"child": 2}, transactions[0].Metrics)
+	equals(t, 0, len(request.Extensions))
+}
+
+func TestWithTraceContext(t *testing.T) {
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	const traceState = "vendor=value"
+
+	var requests int
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		requests++
+		equals(t, traceParent, req.Header.Get("traceparent"))
+		equals(t, traceState, req.Header.Get("tracestate"))
+
+		if req.Method == http.MethodPost {
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+				Header:     make(http.Header),
+			}
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GetAuthSuccess())),
+			Header:     make(http.Header),
+		}
+	}))
+
+	ctx := ContextWithTraceContext(context.Background(), TraceContext{TraceParent: traceParent, TraceState: traceState})
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	if _, err := c.AuthorizeWithOptions(apiCall, WithTraceContext(ctx)); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	if _, err := c.AuthRepWithOptions(apiCall, WithTraceContext(ctx)); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	if _, err := c.ReportWithOptions(apiCall, WithTraceContext(ctx)); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+	equals(t, 3, requests)
+
+	c = threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		if _, ok := req.Header["Traceparent"]; ok {
+			t.Error("unexpected traceparent header when no trace context is provided")
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GetAuthSuccess())),
+			Header:     make(http.Header),
+		}
+	}))
+	if _, err := c.AuthorizeWithOptions(apiCall, WithTraceContext(context.Background())); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	respondWith := func(status int, body string) *http.Client {
+		return NewTestClient(testutil.RespondWith(testutil.NewResponse(status).WithBody(body)))
+	}
+
+	badURLClient := &Client{
+		backendHost: "/some/invalid/value%_",
+		baseURL:     "/some/invalid/value%_",
+		httpClient:  http.DefaultClient,
+	}
+
+	_, err := badURLClient.Authorize(apiCall)
+	if !errors.Is(err, threescale.ErrHTTPBuild) {
+		t.Errorf("expected ErrHTTPBuild but got %v", err)
+	}
+
+	_, err = badURLClient.Report(apiCall)
+	if !errors.Is(err, threescale.ErrHTTPBuild) {
+		t.Errorf("expected ErrHTTPBuild but got %v", err)
+	}
+
+	_, err = threeScaleTestClient(t, respondWith(http.StatusServiceUnavailable, "")).Authorize(apiCall)
+	if !errors.Is(err, threescale.ErrBackend5xx) {
+		t.Errorf("expected ErrBackend5xx but got %v", err)
+	}
+
+	_, err = threeScaleTestClient(t, respondWith(http.StatusInternalServerError, "")).Report(apiCall)
+	if !errors.Is(err, threescale.ErrBackend5xx) {
+		t.Errorf("expected ErrBackend5xx but got %v", err)
+	}
+
+	var backendErr *threescale.BackendError
+	if !errors.As(err, &backendErr) || backendErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected BackendError but got %v", err)
+	}
+
+	networkFailure := &http.Client{Transport: failingTransport{}}
+	_, err = threeScaleTestClient(t, networkFailure).Authorize(apiCall)
+	var transportErr *threescale.TransportError
+	if !errors.As(err, &transportErr) || errors.Is(err, threescale.ErrBackend5xx) {
+		t.Errorf("expected TransportError but got %v", err)
+	}
+
+	_, err = threeScaleTestClient(t, networkFailure).Report(apiCall)
+	if !errors.As(err, &transportErr) {
+		t.Errorf("expected TransportError but got %v", err)
+	}
+
+	_, err = threeScaleTestClient(t, respondWith(http.StatusOK, "EOF")).Authorize(apiCall)
+	if !errors.Is(err, threescale.ErrDecode) {
+		t.Errorf("expected ErrDecode but got %v", err)
+	}
+
+	_, err = threeScaleTestClient(t, respondWith(http.StatusForbidden, "EOF")).Report(apiCall)
+	if !errors.Is(err, threescale.ErrDecode) {
+		t.Errorf("expected ErrDecode but got %v", err)
+	}
+
+	_, err = threeScaleTestClient(t, respondWith(http.StatusOK, "not-json")).GetVersion()
+	if !errors.Is(err, threescale.ErrDecode) {
+		t.Errorf("expected ErrDecode but got %v", err)
+	}
+
+	// the status code and offending body should be available to aid debugging
+	if !strings.Contains(err.Error(), "status: 200") || !strings.Contains(err.Error(), `body: "not-json"`) {
+		t.Errorf("expected decode error to include status and body but got %v", err)
+	}
+
+	_, err = threeScaleTestClient(t, respondWith(http.StatusOK, strings.Repeat("x", 1024))).Authorize(apiCall)
+	if !errors.Is(err, threescale.ErrDecode) {
+		t.Errorf("expected ErrDecode but got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), strings.Repeat("x", maxBodySnippet)+`..."`) {
+		t.Errorf("expected body snippet to be truncated but got %v", err)
+	}
+}
+
+// a 409 from backend is a denial rather than an error and must be handled uniformly across auth and report
+func TestClient_UnexpectedRedirect(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	httpClient := NewTestClient(testutil.RespondWith(
+		testutil.NewResponse(http.StatusFound).
+			WithHeader("Location", "https://backend.example.com/transactions/authorize.xml").
+			WithBody("<html>Moved</html>"),
+	))
+	// the redirect is not followed so is returned to the Client
+	httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	c := threeScaleTestClient(t, httpClient)
+
+	authResult, err := c.Authorize(apiCall)
+	if !errors.Is(err, threescale.ErrUnexpectedRedirect) {
+		t.Fatalf("expected ErrUnexpectedRedirect but got %v", err)
+	}
+	if !strings.Contains(err.Error(), "https://backend.example.com/transactions/authorize.xml") {
+		t.Errorf("expected error to include the location but got %s", err.Error())
+	}
+	equals(t, false, authResult.Authorized)
+
+	reportResult, err := c.Report(apiCall)
+	if !errors.Is(err, threescale.ErrUnexpectedRedirect) {
+		t.Fatalf("expected ErrUnexpectedRedirect but got %v", err)
+	}
+	equals(t, false, reportResult.Accepted)
+}
+
+func TestClient_LimitExceeded(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	respondWith := func(body string) *http.Client {
+		return NewTestClient(testutil.RespondWith(testutil.NewResponse(http.StatusConflict).WithBody(body)))
+	}
+
+	authResult, err := threeScaleTestClient(t, respondWith(fake.GetLimitExceededResp())).AuthRep(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	if !authResult.IsLimitExceeded() {
+		t.Errorf("expected authorization result to have exceeded limits")
+	}
+
+	reportResult, err := threeScaleTestClient(t, respondWith(fake.NewErrorResp("limits_exceeded", "usage limits are exceeded"))).Report(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	if !reportResult.IsLimitExceeded() {
+		t.Errorf("expected report result to have exceeded limits")
+	}
+	equals(t, http.StatusConflict, reportResult.HTTPStatus())
+}
+
+func TestClient_ReportRateLimits(t *testing.T) {
+	httpClient := NewTestClient(testutil.RespondWith(
+		testutil.NewResponse(http.StatusAccepted).
+			WithHeader(limitRemainingHeaderKey, "42").
+			WithHeader(limitResetHeaderKey, "30"),
+	))
+	c := threeScaleTestClient(t, httpClient)
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	result, err := c.Report(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, result.Accepted)
+	if result.RateLimits != nil {
+		t.Errorf("expected no rate limits unless the extension is enabled but got %v", result.RateLimits)
+	}
+
+	apiCall.Extensions = api.NewExtensions().WithLimitHeaders().Build()
+	result, err = c.Report(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, result.Accepted)
+	equals(t, &api.RateLimits{LimitRemaining: 42, LimitReset: 30}, result.RateLimits)
+}
+
+// regression test to ensure usage reports are decoded on a 409 so callers can identify the period which was exceeded
+func TestClient_AuthorizeLimitExceededUsageReports(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	httpClient := NewTestClient(testutil.RespondWith(testutil.NewResponse(http.StatusConflict).WithXML(fake.GetLimitExceededResp())))
+	result, err := threeScaleTestClient(t, httpClient).Authorize(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	equals(t, false, result.Authorized)
+	equals(t, "usage limits are exceeded", result.RejectionReason)
+
+	expect := []api.UsageReport{
+		{
+			PeriodWindow: api.PeriodWindow{Period: api.Minute, Start: 1535813040, End: 1535813100},
+			MaxValue:     1,
+			CurrentValue: 1,
+		},
+	}
+	equals(t, expect, result.UsageReports["hits"])
+}
+
+// regression test to ensure the message is not lost when backend responds to an authorization with an error document
+func TestClient_AuthorizeErrorResponse(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "invalid"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	httpClient := NewTestClient(testutil.RespondWith(testutil.NewResponse(http.StatusForbidden).WithXML(fake.GenInvalidUserKey("invalid"))))
+	c := threeScaleTestClient(t, httpClient)
+
+	result, err := c.Authorize(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, false, result.Authorized)
+	equals(t, "user_key_invalid", result.ErrorCode)
+	equals(t, `user key "invalid" is invalid`, result.RejectionReason)
+
+	result, err = c.AuthRep(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, false, result.Authorized)
+	equals(t, "user_key_invalid", result.ErrorCode)
+	equals(t, `user key "invalid" is invalid`, result.RejectionReason)
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestClient_Enforce(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, authRepEndpoint, req.URL.Path)
+
+		extensions, _ := url.ParseQuery(req.Header.Get(enableExtensions))
+		equals(t, "1", extensions.Get(api.LimitExtension))
+		equals(t, "1", extensions.Get(RejectionReasonHeaderExtension))
+
+		return testutil.NewResponse(http.StatusConflict).
+			WithHeader(limitRemainingHeaderKey, "0").
+			WithHeader(limitResetHeaderKey, "30").
+			WithHeader("3scale-Rejection-Reason", "limits_exceeded").
+			WithXML(fake.GetLimitExceededResp()).
+			Build()
+	}))
+
+	decision, err := c.Enforce(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, threescale.EnforcementDecision{
+		HTTPStatus: http.StatusTooManyRequests,
+		RetryAfter: 30 * time.Second,
+		Reason:     "limits_exceeded",
+	}, *decision)
+	equals(t, api.Extensions(nil), apiCall.Extensions)
+
+	unreachable := threeScaleTestClient(t, &http.Client{Transport: failingTransport{}})
+	if _, err := unreachable.Enforce(apiCall); err == nil {
+		t.Errorf("expected error when backend is unreachable")
+	}
+}
+
+func TestClient_AuthorizeDecision(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}}},
+	}
+
+	unreachable := threeScaleTestClient(t, &http.Client{Transport: failingTransport{}})
+	equals(t, true, unreachable.AuthorizeDecision(apiCall, threescale.FailOpen))
+	equals(t, false, unreachable.AuthorizeDecision(apiCall, threescale.FailClosed))
+
+	denied := threeScaleTestClient(t, NewTestClient(testutil.RespondWith(
+		testutil.NewResponse(http.StatusConflict).WithXML(fake.GetLimitExceededResp()),
+	)))
+	equals(t, false, denied.AuthorizeDecision(apiCall, threescale.FailOpen))
+}
+
+func TestWithRequestModifier(t *testing.T) {
+	var requests int
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		requests++
+		equals(t, "abc-123", req.Header.Get("X-Request-ID"))
+		equals(t, "second", req.Header.Get("X-Order"))
+
+		if req.Method == http.MethodPost {
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+				Header:     make(http.Header),
+			}
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GetAuthSuccess())),
+			Header:     make(http.Header),
+		}
+	}))
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	options := []Option{
+		WithRequestModifier(func(req *http.Request) {
+			req.Header.Set("X-Request-ID", "abc-123")
+			req.Header.Set("X-Order", "first")
+		}),
+		WithRequestModifier(nil),
+		WithRequestModifier(func(req *http.Request) {
+			req.Header.Set("X-Order", "second")
+		}),
+	}
+
+	if _, err := c.AuthorizeWithOptions(apiCall, options...); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	if _, err := c.ReportWithOptions(apiCall, options...); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+	equals(t, 2, requests)
+}
+
+func TestWithDefaultExtensions(t *testing.T) {
+	var extensions []string
+	httpClient := NewTestClient(func(req *http.Request) *http.Response {
+		extensions = append(extensions, req.Header.Get(enableExtensions))
+		if req.URL.Path == reportEndpoint {
+			return testutil.NewResponse(http.StatusAccepted).Build()
+		}
+		return testutil.NewResponse(http.StatusOK).WithBody(getHierarchyXML(t)).Build()
+	})
+
+	defaults := api.Extensions{api.HierarchyExtension: "1"}
+	c, err := NewClient(defaultBackendUrl, httpClient, WithDefaultExtensions(defaults))
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	// modifying the provided extensions must not affect the client
+	defaults[api.LimitExtension] = "1"
+
+	request := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	result, err := c.Authorize(request)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, api.Hierarchy{"hits": []string{"example", "sample", "test"}}, result.Hierarchy)
+
+	if _, err := c.Report(request); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	// extensions provided by the request take precedence
+	request.Extensions = api.Extensions{api.HierarchyExtension: "0"}
+	if _, err := c.Authorize(request); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	equals(t, []string{"hierarchy=1", "hierarchy=1", "hierarchy=0"}, extensions)
+	equals(t, api.Extensions{api.HierarchyExtension: "0"}, request.Extensions)
+}
+
+func TestWithMaxResponseBytes(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	body := fake.GetAuthSuccess()
+	httpClient := NewTestClient(testutil.RespondWith(testutil.NewResponse(http.StatusOK).WithXML(body)))
+
+	c, err := NewClient(defaultBackendUrl, httpClient, WithMaxResponseBytes(int64(len(body))))
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	if _, err := c.Authorize(apiCall); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	c, err = NewClient(defaultBackendUrl, httpClient, WithMaxResponseBytes(int64(len(body)-1)))
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	_, err = c.Authorize(apiCall)
+	if !errors.Is(err, threescale.ErrDecode) || !strings.Contains(err.Error(), "exceeds the maximum size") {
+		t.Errorf("expected error for response exceeding maximum size but got %v", err)
+	}
+
+	// the default applies when no limit is configured
+	huge := strings.Repeat(" ", defaultMaxResponseBytes) + body
+	_, err = threeScaleTestClient(t, NewTestClient(testutil.RespondWith(testutil.NewResponse(http.StatusOK).WithXML(huge)))).Authorize(apiCall)
+	if !errors.Is(err, threescale.ErrDecode) {
+		t.Errorf("expected error for response exceeding default maximum size but got %v", err)
+	}
+}
+
+func TestWithSkipBodyParsing(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Extensions:   api.NewExtensions().WithHierarchy().WithLimitHeaders().Build(),
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	inputs := []struct {
+		name     string
+		response *testutil.ResponseBuilder
+		expect   *threescale.AuthorizeResult
+	}{
+		{
+			name:     "Test authorized from status",
+			response: testutil.NewResponse(http.StatusOK).WithXML(getHierarchyXML(t)).WithHeader(limitRemainingHeaderKey, "5"),
+			expect: &threescale.AuthorizeResult{
+				Authorized:          true,
+				AuthorizeExtensions: threescale.AuthorizeExtensions{RateLimits: &api.RateLimits{LimitRemaining: 5}},
+			},
+		},
+		{
+			name: "Test denied from status",
+			response: testutil.NewResponse(http.StatusConflict).
+				WithXML(fake.GetLimitExceededResp()).
+				WithHeader("3scale-Rejection-Reason", "limits_exceeded"),
+			expect: &threescale.AuthorizeResult{
+				ErrorCode:           "limits_exceeded",
+				AuthorizeExtensions: threescale.AuthorizeExtensions{RateLimits: &api.RateLimits{}},
+			},
+		},
+		{
+			name:     "Test body which cannot be decoded is ignored",
+			response: testutil.NewResponse(http.StatusOK).WithBody("not-xml"),
+			expect: &threescale.AuthorizeResult{
+				Authorized:          true,
+				AuthorizeExtensions: threescale.AuthorizeExtensions{RateLimits: &api.RateLimits{}},
+			},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			c := threeScaleTestClient(t, NewTestClient(testutil.RespondWith(input.response)))
+			result, err := c.AuthRepWithOptions(apiCall, WithSkipBodyParsing())
+			if err != nil {
+				t.Fatalf("unexpected error - %s", err.Error())
+			}
+
+			equals(t, input.expect.Authorized, result.Authorized)
+			equals(t, input.expect.ErrorCode, result.ErrorCode)
+			equals(t, input.expect.AuthorizeExtensions, result.AuthorizeExtensions)
+			equals(t, api.UsageReports(nil), result.UsageReports)
+		})
+	}
+
+	c := threeScaleTestClient(t, NewTestClient(testutil.RespondWith(testutil.NewResponse(http.StatusServiceUnavailable))))
+	if _, err := c.AuthorizeWithOptions(apiCall, WithSkipBodyParsing()); !errors.Is(err, threescale.ErrBackend5xx) {
+		t.Errorf("expected ErrBackend5xx but got %v", err)
+	}
+}
+
+func TestWithClientMetadata(t *testing.T) {
+	seen := make(map[string]bool)
+	httpClient := NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, "my-gateway/1.2.3", req.Header.Get("3scale-client-info"))
+		seen[req.URL.Path] = true
+
+		switch req.URL.Path {
+		case statusEndpoint:
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"status":"ok","version":{"backend":"2.96.2"}}`)),
+				Header:     make(http.Header),
+			}
+		case reportEndpoint:
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+				Header:     make(http.Header),
+			}
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GetAuthSuccess())),
+			Header:     make(http.Header),
+		}
+	})
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	c, _ := NewClient(defaultBackendUrl, httpClient, WithClientMetadata("my-gateway", "1.2.3"))
+	if _, err := c.Authorize(apiCall); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+	if _, err := c.AuthRep(apiCall); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+	if _, err := c.Report(apiCall); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+	if _, err := c.GetStatus(); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	equals(t, map[string]bool{authzEndpoint: true, authRepEndpoint: true, reportEndpoint: true, statusEndpoint: true}, seen)
+}
+
+func TestWithUserAgent(t *testing.T) {
+	expectUserAgent := DefaultUserAgent
+	httpClient := NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, expectUserAgent, req.Header.Get("User-Agent"))
+
+		switch req.URL.Path {
+		case statusEndpoint:
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"status":"ok","version":{"backend":"2.96.2"}}`)),
+				Header:     make(http.Header),
+			}
+		case reportEndpoint:
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+				Header:     make(http.Header),
+			}
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GetAuthSuccess())),
+			Header:     make(http.Header),
+		}
+	})
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	exercise := func(c *Client) {
+		t.Helper()
+		if _, err := c.Authorize(apiCall); err != nil {
+			t.Errorf("unexpected error - %s", err.Error())
+		}
+		if _, err := c.Report(apiCall); err != nil {
+			t.Errorf("unexpected error - %s", err.Error())
+		}
+		if _, err := c.GetVersion(); err != nil {
+			t.Errorf("unexpected error - %s", err.Error())
+		}
+	}
+
+	exercise(threeScaleTestClient(t, httpClient))
+
+	expectUserAgent = "my-gateway/1.0"
+	c, _ := NewClient(defaultBackendUrl, httpClient, WithUserAgent(expectUserAgent))
+	exercise(c)
+}
+
+func TestRequestBuilder_Context(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	c := threeScaleTestClient(t, nil)
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	for _, k := range []kind{auth, authRep, report, oauthAuth, oauthAuthRep} {
+		req, err := c.requestBuilder().build(ctx, apiCall, c.backendURL, k)
+		if err != nil {
+			t.Fatalf("unexpected error - %s", err.Error())
+		}
+		equals(t, "value", req.Context().Value(key{}))
+		equals(t, c.backendURL.Host, req.Host)
+	}
+
+	req, err := c.requestBuilder().build(ctx, apiCall, c.backendURL, report)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	b, _ := ioutil.ReadAll(body)
+	equals(t, req.ContentLength, int64(len(b)))
+}
+
+func TestRequestBuilder_EmptyService(t *testing.T) {
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		t.Error("unexpected request sent for empty service")
+		return nil
+	}))
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	for _, k := range []kind{auth, authRep, report} {
+		req, err := c.requestBuilder().build(context.TODO(), apiCall, c.backendURL, k)
+		if err == nil {
+			t.Fatalf("expected error building request with empty service but got %v", req.URL)
+		}
+		if wrapped := c.wrapError(err); !errors.Is(wrapped, threescale.ErrHTTPBuild) {
+			t.Errorf("unexpected error - %s", wrapped.Error())
+		}
+	}
+
+	if _, err := c.Authorize(apiCall); err == nil {
+		t.Error("expected error authorizing with empty service")
+	}
+	if _, err := c.Report(apiCall); err == nil {
+		t.Error("expected error reporting with empty service")
+	}
+}
+
+func TestWithRequestResponseDump(t *testing.T) {
+	httpClient := NewTestClient(func(req *http.Request) *http.Response {
+		if req.URL.Path == reportEndpoint {
+			body, _ := ioutil.ReadAll(req.Body)
+			values, _ := url.ParseQuery(string(body))
+			equals(t, "app", values.Get("transactions[0][app_id]"))
+			return testutil.NewResponse(http.StatusAccepted).Build()
+		}
+		return testutil.NewResponse(http.StatusOK).WithXML(fake.GetAuthSuccess()).Build()
+	})
+
+	var dump bytes.Buffer
+	c, err := NewClient(defaultBackendUrl, httpClient, WithRequestResponseDump(&dump))
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	// the response body must remain available to be decoded once dumped
+	authResult, err := c.Authorize(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, authResult.Authorized)
+
+	for _, expect := range []string{"GET " + authzEndpoint, " 200 OK", "<authorized>true</authorized>"} {
+		if !strings.Contains(dump.String(), expect) {
+			t.Errorf("expected dump to contain %q but got %s", expect, dump.String())
+		}
+	}
+
+	// the request body must remain available to be sent once dumped
+	dump.Reset()
+	reportResult, err := c.Report(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, reportResult.Accepted)
+
+	for _, expect := range []string{"POST " + reportEndpoint, url.QueryEscape("transactions[0][app_id]") + "=app", " 202 Accepted"} {
+		if !strings.Contains(dump.String(), expect) {
+			t.Errorf("expected dump to contain %q but got %s", expect, dump.String())
+		}
+	}
+}
+
+func TestWithResponseFormat(t *testing.T) {
+	const authJSON = `{
+  "authorized": true,
+  "hierarchy": {"hits": ["example", "other"]},
+  "usage_reports": [
+    {
+      "metric": "hits",
+      "period": "minute",
+      "period_start": "2019-02-22 14:32:00 +0000",
+      "period_end": "2019-02-22 14:33:00 +0000",
+      "max_value": 4,
+      "current_value": 1
+    },
+    {"metric": "example", "period": "eternity", "max_value": 10, "current_value": 2}
+  ]
+}`
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Extensions:   api.Extensions{api.HierarchyExtension: "1"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	newClient := func(rb *testutil.ResponseBuilder) *Client {
+		t.Helper()
+		httpClient := NewTestClient(func(req *http.Request) *http.Response {
+			equals(t, "application/json", req.Header.Get("Accept"))
+			return rb.Build()
+		})
+		c, err := NewClient(defaultBackendUrl, httpClient, WithResponseFormat(JSON))
+		if err != nil {
+			t.Fatalf("unexpected error - %s", err.Error())
+		}
+		return c
+	}
+
+	c := newClient(testutil.NewResponse(http.StatusOK).WithHeader("Content-Type", "application/json").WithBody(authJSON))
+	result, err := c.Authorize(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, result.Authorized)
+	equals(t, api.Hierarchy{"hits": {"example", "other"}}, result.Hierarchy)
+	equals(t, api.UsageReports{
+		"hits": {
+			{PeriodWindow: api.PeriodWindow{Period: api.Minute, Start: 1550845920, End: 1550845980}, MaxValue: 4, CurrentValue: 1},
+		},
+		"example": {
+			{PeriodWindow: api.PeriodWindow{Period: api.Eternity}, MaxValue: 10, CurrentValue: 2},
+		},
+	}, result.UsageReports)
+
+	errJSON := `{"error": {"code": "user_key_invalid", "message": "user key \"key\" is invalid"}}`
+	c = newClient(testutil.NewResponse(http.StatusForbidden).WithHeader("Content-Type", "application/json").WithBody(errJSON))
+	result, err = c.Authorize(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, false, result.Authorized)
+	equals(t, "user_key_invalid", result.ErrorCode)
+	equals(t, `user key "key" is invalid`, result.RejectionReason)
+
+	reportResult, err := c.Report(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, false, reportResult.Accepted)
+	equals(t, "user_key_invalid", reportResult.ErrorCode)
+	equals(t, `user key "key" is invalid`, reportResult.RejectionReason)
+
+	// responses which backend returns as XML regardless are decoded as XML
+	c = newClient(testutil.NewResponse(http.StatusOK).WithXML(fake.GetAuthSuccess()))
+	result, err = c.Authorize(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, result.Authorized)
+
+	// XML remains the default
+	httpClient := NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, "application/xml", req.Header.Get("Accept"))
+		return testutil.NewResponse(http.StatusOK).WithXML(fake.GetAuthSuccess()).Build()
+	})
+	if _, err := threeScaleTestClient(t, httpClient).Authorize(apiCall); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+}
+
+func TestClient_Validation(t *testing.T) {
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		t.Error("unexpected request sent for invalid input")
+		return nil
+	}))
+
+	apiCall := threescale.Request{
+		Auth:    api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service: "svc",
+	}
+
+	if _, err := c.Authorize(apiCall); !errors.Is(err, threescale.ErrValidation) {
+		t.Errorf("expected validation error but got %v", err)
+	}
+
+	if _, err := c.AuthRep(apiCall); !errors.Is(err, threescale.ErrValidation) {
+		t.Errorf("expected validation error but got %v", err)
+	}
+
+	if _, err := c.Report(apiCall); !errors.Is(err, threescale.ErrValidation) {
+		t.Errorf("expected validation error but got %v", err)
+	}
+
+	apiCall.Transactions = []api.Transaction{{Params: api.Params{AppID: "app"}}}
+	apiCall.Auth.Value = ""
+	if _, err := c.Authorize(apiCall); !errors.Is(err, threescale.ErrValidation) {
+		t.Errorf("expected validation error but got %v", err)
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(getUsageReportXML(t)))
+	gz.Close()
+
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, "gzip", req.Header.Get("Accept-Encoding"))
+		header := make(http.Header)
+		header.Set("Content-Encoding", "gzip")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewReader(compressed.Bytes())),
+			Header:     header,
+		}
+	}))
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	resp, err := c.AuthorizeWithOptions(apiCall, WithCompression())
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, resp.Authorized)
+	equals(t, 2, len(resp.UsageReports["hits"]))
+
+	c = threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		header := make(http.Header)
+		header.Set("Content-Encoding", "gzip")
+		return &http.Response{
+			StatusCode: http.StatusAccepted,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+			Header:     header,
+		}
+	}))
+
+	reportResp, err := c.ReportWithOptions(apiCall, WithCompression())
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, reportResp.Accepted)
+}
+
+func TestWithCompressionInvalidBodyIsInstrumented(t *testing.T) {
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		return testutil.NewResponse(http.StatusOK).
+			WithHeader("Content-Encoding", "gzip").
+			WithBody("not gzip").
+			Build()
+	}))
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	var statusCodes []int
+	cb := func(ctx context.Context, hostName string, statusCode int, requestDuration time.Duration) {
+		statusCodes = append(statusCodes, statusCode)
+	}
+	options := []Option{WithCompression(), WithInstrumentationCallback(cb), WithSynchronousInstrumentation()}
+
+	if _, err := c.AuthorizeWithOptions(apiCall, options...); err == nil {
+		t.Error("expected error decompressing authorization response")
+	}
+	if _, err := c.ReportWithOptions(apiCall, options...); err == nil {
+		t.Error("expected error decompressing report response")
+	}
+
+	// backend responded, so the requests must be instrumented regardless of the failure to decompress
+	equals(t, []int{http.StatusOK, http.StatusOK}, statusCodes)
+}
+
+func TestWithSynchronousInstrumentation(t *testing.T) {
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		if req.Method == http.MethodPost {
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+				Header:     make(http.Header),
+			}
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(fake.GetAuthSuccess())),
+			Header:     make(http.Header),
+		}
+	}))
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	// the statuses are read without synchronisation, so the race detector will flag any
+	// callback which has not completed prior to the api call returning
+	var statuses []int
+	cb := func(ctx context.Context, hostName string, statusCode int, requestDuration time.Duration) {
+		statuses = append(statuses, statusCode)
+	}
+	options := []Option{WithInstrumentationCallback(cb), WithSynchronousInstrumentation()}
+
+	if _, err := c.AuthorizeWithOptions(apiCall, options...); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+	equals(t, []int{http.StatusOK}, statuses)
+
+	if _, err := c.ReportWithOptions(apiCall, options...); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+	equals(t, []int{http.StatusOK, http.StatusAccepted}, statuses)
+}
+
+func TestWithBackendURL(t *testing.T) {
+	var hosts []string
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		hosts = append(hosts, req.URL.Scheme+"://"+req.URL.Host)
+		if req.Method == http.MethodPost {
+			return testutil.NewResponse(http.StatusAccepted).Build()
+		}
+		return testutil.NewResponse(http.StatusOK).WithXML(fake.GetAuthSuccess()).Build()
+	}))
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	var peers []string
+	cb := func(ctx context.Context, hostName string, statusCode int, requestDuration time.Duration) {
+		peers = append(peers, hostName)
+	}
+	instrumentation := []Option{WithInstrumentationCallback(cb), WithSynchronousInstrumentation()}
+	override := append([]Option{WithBackendURL("http://onprem.example.com:3000")}, instrumentation...)
+
+	if _, err := c.AuthorizeWithOptions(apiCall, instrumentation...); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	result, err := c.AuthorizeWithOptions(apiCall, override...)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, strings.HasPrefix(result.RequestURL, "http://onprem.example.com:3000"+authzEndpoint))
+
+	if _, err := c.ReportWithOptions(apiCall, override...); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	if _, err := c.ReportWithOptions(apiCall, instrumentation...); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	equals(t, []string{defaultBackendUrl, "http://onprem.example.com:3000", "http://onprem.example.com:3000", defaultBackendUrl}, hosts)
+	equals(t, []string{"su1.3scale.net", "onprem.example.com", "onprem.example.com", "su1.3scale.net"}, peers)
+	equals(t, "su1.3scale.net", c.GetPeer())
+
+	if _, err := c.AuthorizeWithOptions(apiCall, WithBackendURL("ftp://onprem.example.com")); !errors.Is(err, threescale.ErrHTTPBuild) {
+		t.Errorf("expected build error for unsupported scheme but got %v", err)
+	}
+	equals(t, 4, len(hosts))
+}
+
+func TestInstrumentationCallbackContext(t *testing.T) {
+	type correlationKey struct{}
+
+	// the transport sets the request on the response as net/http does, and the client timeout
+	// causes the request to be sent with a context derived from the one provided
+	httpClient := &http.Client{
+		Transport: testutil.RoundTripFunc(func(req *http.Request) *http.Response {
+			resp := testutil.NewResponse(http.StatusOK).WithXML(fake.GetAuthSuccess()).Build()
+			if req.Method == http.MethodPost {
+				resp = testutil.NewResponse(http.StatusAccepted).Build()
+			}
+			resp.Request = req
+			return resp
+		}),
+		Timeout: time.Minute,
+	}
+	c := threeScaleTestClient(t, httpClient)
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	var correlationIDs []interface{}
+	var hasDeadline []bool
+	cb := func(ctx context.Context, hostName string, statusCode int, requestDuration time.Duration) {
+		correlationIDs = append(correlationIDs, ctx.Value(correlationKey{}))
+		_, ok := ctx.Deadline()
+		hasDeadline = append(hasDeadline, ok)
+	}
+
+	ctx := context.WithValue(context.Background(), correlationKey{}, "abc-123")
+	options := []Option{WithContext(ctx), WithInstrumentationCallback(cb), WithSynchronousInstrumentation()}
+
+	if _, err := c.AuthorizeWithOptions(apiCall, options...); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	if _, err := c.ReportWithOptions(apiCall, options...); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	equals(t, []interface{}{"abc-123", "abc-123"}, correlationIDs)
+	equals(t, []bool{true, true}, hasDeadline)
+}
+
+func TestInstrumentationCallbackEndpoint(t *testing.T) {
+	httpClient := NewTestClient(func(req *http.Request) *http.Response {
+		if req.Method == http.MethodPost {
+			return testutil.NewResponse(http.StatusAccepted).Build()
+		}
+		return testutil.NewResponse(http.StatusOK).WithXML(fake.GetAuthSuccess()).Build()
+	})
+	c := threeScaleTestClient(t, httpClient)
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	var endpoints []string
+	cb := func(ctx context.Context, hostName string, statusCode int, requestDuration time.Duration) {
+		endpoint, ok := EndpointFromContext(ctx)
+		if !ok {
+			t.Errorf("expected endpoint to be present in context")
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	options := []Option{WithInstrumentationCallback(cb), WithSynchronousInstrumentation()}
+
+	if _, err := c.AuthorizeWithOptions(apiCall, options...); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	if _, err := c.AuthRepWithOptions(apiCall, options...); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	if _, err := c.ReportWithOptions(apiCall, options...); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	equals(t, []string{"authorize", "authrep", "report"}, endpoints)
+
+	if _, ok := EndpointFromContext(context.Background()); ok {
+		t.Errorf("expected endpoint to be absent from a context not provided by the client")
+	}
+}
+
+func TestClient_GetVersion(t *testing.T) {
+	// expect err on simulate network err
+	c := &Client{
+		httpClient: &http.Client{
+			Transport:     nil,
+			CheckRedirect: nil,
+			Jar:           nil,
+			Timeout:       time.Nanosecond,
+		},
+	}
+	_, err := c.GetVersion()
+	if err == nil {
+		t.Error("expected network err caused by timeout exceeded")
+	}
+
+	// expect err on badly configured client
+	c = &Client{
+		backendHost: "/some/invalid/value%_",
+		baseURL:     "/some/invalid/value%_",
+		httpClient: &http.Client{
+			Transport:     nil,
+			CheckRedirect: nil,
+			Jar:           nil,
+			Timeout:       time.Nanosecond,
+		},
+	}
+	_, err = c.GetVersion()
+	if err == nil {
+		t.Error("expected err building request")
+	}
+
+	// expect err on decode err
+	httpClientTriggerDecodeErr := NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, req.URL.Path, statusEndpoint)
+		resp := `not-json`
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(resp))}
+	})
+	c = threeScaleTestClient(t, httpClientTriggerDecodeErr)
+	_, err = c.GetVersion()
+	if err == nil {
+		t.Error("expected err decoding json")
+	}
+
+	// happy path
+	httpClientSuccess := NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, req.URL.Path, statusEndpoint)
+		resp := `{"status":"ok","version":{"backend":"2.96.2"}}`
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(resp))}
+	})
+
+	c = threeScaleTestClient(t, httpClientSuccess)
+	version, err := c.GetVersion()
+	if err != nil {
+		t.Error("unexpected error calling GetVersion()")
+	}
+
+	if version != "2.96.2" {
+		t.Error("unexpected result calling GetVersion()")
+	}
+
+}
+
+func TestClient_GetStatus(t *testing.T) {
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, req.URL.Path, statusEndpoint)
+		resp := `{"status":"degraded","version":{"backend":"2.96.2"}}`
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(resp))}
+	}))
+
+	status, err := c.GetStatus()
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, &threescale.BackendStatus{Status: "degraded", BackendVersion: "2.96.2"}, status)
+
+	c = threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(`not-json`))}
+	}))
+	if _, err := c.GetStatus(); err == nil {
+		t.Error("expected err decoding json")
+	}
+}
+
+func TestClient_GetStatusWithBasePath(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+
+	httpClient := NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, "/backend"+statusEndpoint, req.URL.Path)
+		equals(t, "value", req.Context().Value(key{}))
+		resp := `{"status":"ok","version":{"backend":"2.96.2"}}`
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(resp))}
+	})
+
+	c, err := NewClient("https://example.com/backend", httpClient)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
 	}
+
+	if _, err := c.GetStatusWithContext(ctx); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+}
+
+func TestClient_Ping(t *testing.T) {
+	respondWith := func(status int) *Client {
+		return threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+			equals(t, statusEndpoint, req.URL.Path)
+			return &http.Response{
+				StatusCode: status,
+				Status:     http.StatusText(status),
+				Body:       ioutil.NopCloser(bytes.NewBufferString("not-json")),
+			}
+		}))
+	}
+
+	if err := respondWith(http.StatusOK).Ping(context.Background()); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	if err := respondWith(http.StatusNotFound).Ping(context.Background()); err == nil {
+		t.Error("expected error for non 2xx status")
+	}
+
+	if err := respondWith(http.StatusServiceUnavailable).Ping(context.Background()); !errors.Is(err, threescale.ErrBackend5xx) {
+		t.Errorf("expected ErrBackend5xx but got %v", err)
+	}
+
+	c := &Client{baseURL: defaultBackendUrl, httpClient: &http.Client{Timeout: time.Nanosecond}}
+	if err := c.Ping(context.Background()); err == nil {
+		t.Error("expected network err caused by timeout exceeded")
+	}
+}
+
+type idleConnCloser struct {
+	http.RoundTripper
+	closed bool
+}
+
+func (i *idleConnCloser) CloseIdleConnections() {
+	i.closed = true
+}
+
+func TestClient_Close(t *testing.T) {
+	transport := &idleConnCloser{RoundTripper: http.DefaultTransport}
+	c := threeScaleTestClient(t, &http.Client{Transport: transport})
+
+	if err := c.Close(); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+	equals(t, true, transport.closed)
+}
+
+func TestClient_WithContext(t *testing.T) {
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	c := threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, "value", req.Context().Value(ctxKey{}))
+		body := fake.GetAuthSuccess()
+		if req.Method == http.MethodPost {
+			body = ""
+		}
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(body))}
+	}))
+
+	request := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}}},
+	}
+
+	if _, err := c.AuthorizeWithContext(ctx, request); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	if _, err := c.ReportWithContext(ctx, request); err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c = threeScaleTestClient(t, &http.Client{Transport: http.DefaultTransport})
+	if _, err := c.ReportWithContext(cancelled, request); err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Errorf("expected cancelled context to abort the request but got %v", err)
+	}
+}
+
+func TestClient_GetVersionWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := threeScaleTestClient(t, &http.Client{Transport: http.DefaultTransport})
+	if _, err := c.GetVersionWithContext(ctx); err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Errorf("expected cancelled context to abort the request but got %v", err)
+	}
+
+	c = threeScaleTestClient(t, NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, ctx, req.Context())
+		resp := `{"status":"ok","version":{"backend":"2.96.2"}}`
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(resp))}
+	}))
+	version, err := c.GetVersionWithContext(ctx)
+	if err != nil {
+		t.Errorf("unexpected error - %s", err.Error())
+	}
+	equals(t, "2.96.2", version)
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := NewClient("ftp://invalid.com", http.DefaultClient)
+	if err == nil {
+		t.Error("expected error for invalid scheme")
+	}
+
+	c, err := NewClient(defaultBackendUrl, http.DefaultClient)
+	if err != nil {
+		t.Error("unexpected error when creating client")
+	}
+
+	if c.GetPeer() != "su1.3scale.net" {
+		t.Error("unexpected hostname set via constructor")
+	}
+}
+
+func TestNewClientWithConfig(t *testing.T) {
+	httpClient := NewTestClient(func(req *http.Request) *http.Response {
+		switch req.URL.Path {
+		case "/proxy/authorize":
+			return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(fake.GetAuthSuccess()))}
+		case "/proxy/report":
+			return &http.Response{StatusCode: 202, Body: ioutil.NopCloser(bytes.NewBufferString(""))}
+		case statusEndpoint:
+			return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(""))}
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+			return &http.Response{StatusCode: 404, Body: ioutil.NopCloser(bytes.NewBufferString(""))}
+		}
+	})
+
+	c, err := NewClientWithConfig(defaultBackendUrl, httpClient, EndpointConfig{
+		Authorize: "/proxy/authorize",
+		Report:    "/proxy/report",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	request := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}}},
+	}
+
+	if result, err := c.Authorize(request); err != nil || !result.Authorized {
+		t.Errorf("expected configured authorize endpoint to be called - %v", err)
+	}
+
+	if result, err := c.Report(request); err != nil || !result.Accepted {
+		t.Errorf("expected configured report endpoint to be called - %v", err)
+	}
+
+	// unset paths fall back to their defaults
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("expected default status endpoint to be called - %v", err)
+	}
+}
+
+func TestClient_SetHTTPClient(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	inFlight := make(chan struct{})
+	release := make(chan struct{})
+	original := NewTestClient(func(req *http.Request) *http.Response {
+		close(inFlight)
+		<-release
+		return testutil.NewResponse(http.StatusOK).WithXML(fake.GetAuthSuccess()).Build()
+	})
+
+	var replacementCalls int32
+	replacement := NewTestClient(func(req *http.Request) *http.Response {
+		atomic.AddInt32(&replacementCalls, 1)
+		return testutil.NewResponse(http.StatusForbidden).WithXML(fake.GenInvalidUserKey("key")).Build()
+	})
+
+	c := threeScaleTestClient(t, original)
+
+	done := make(chan *threescale.AuthorizeResult)
+	go func() {
+		result, err := c.Authorize(apiCall)
+		if err != nil {
+			t.Errorf("unexpected error - %s", err.Error())
+		}
+		done <- result
+	}()
+
+	<-inFlight
+	c.SetHTTPClient(replacement)
+
+	result, err := c.Authorize(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, false, result.Authorized)
+	equals(t, int32(1), atomic.LoadInt32(&replacementCalls))
+
+	// the request in flight when the client was replaced completes using the original client
+	close(release)
+	inFlightResult := <-done
+	if inFlightResult == nil || !inFlightResult.Authorized {
+		t.Errorf("expected request in flight to complete with the original client")
+	}
+
+	c.SetHTTPClient(nil)
+	if c.getHTTPClient() == nil || c.getHTTPClient() == replacement {
+		t.Errorf("expected a default client to be constructed")
+	}
+}
+
+func TestClient_SetHTTPClientConcurrently(t *testing.T) {
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	newClient := func() *http.Client {
+		return NewTestClient(testutil.RespondWith(testutil.NewResponse(http.StatusOK).WithXML(fake.GetAuthSuccess())))
+	}
+	c := threeScaleTestClient(t, newClient())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Authorize(apiCall); err != nil {
+				t.Errorf("unexpected error - %s", err.Error())
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			c.SetHTTPClient(newClient())
+		}()
+	}
+	wg.Wait()
+}
+
+func TestClient_GetBaseURL(t *testing.T) {
+	inputs := []struct {
+		backendURL string
+		expectHost string
+		expectPort int
+	}{
+		{backendURL: "https://su1.3scale.net:443", expectHost: "su1.3scale.net", expectPort: 443},
+		{backendURL: "https://example.com", expectHost: "example.com", expectPort: 443},
+		{backendURL: "http://example.com", expectHost: "example.com", expectPort: 80},
+		{backendURL: "http://[::1]:3000", expectHost: "::1", expectPort: 3000},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.backendURL, func(t *testing.T) {
+			c, err := NewClient(input.backendURL, http.DefaultClient)
+			if err != nil {
+				t.Fatalf("unexpected error - %s", err.Error())
+			}
+			equals(t, input.backendURL, c.GetBaseURL())
+			equals(t, input.expectHost, c.GetPeer())
+			equals(t, input.expectPort, c.GetPort())
+		})
+	}
+
+	// a Client which was not built by NewClient has no parsed URL
+	equals(t, 8080, (&Client{baseURL: "http://example.com:8080"}).GetPort())
+	equals(t, 0, (&Client{}).GetPort())
+}
+
+func TestVerifyBackendUrl(t *testing.T) {
+	c, err := NewClient("https://[::1]:443", http.DefaultClient)
+	if err != nil {
+		t.Fatalf("unexpected error for IPv6 host - %s", err.Error())
+	}
+	equals(t, "::1", c.GetPeer())
+
+	for _, invalid := range []string{"https://example.com:70000", "https://example.com:0", "https://::1:443"} {
+		if _, err := NewClient(invalid, http.DefaultClient); err == nil {
+			t.Errorf("expected error for %s", invalid)
+		}
+	}
+}
+
+func TestNewDefaultClient(t *testing.T) {
+	c, _ := NewDefaultClient()
+
+	if c.baseURL != defaultBackendUrl {
+		t.Error("unexpected setting in default client")
+	}
+
+	if c.httpClient.Timeout != defaultTimeout {
+		t.Error("unexpected setting in default client")
+	}
+}
+
+func TestWithMinTLSVersion(t *testing.T) {
+	c, err := NewDefaultClient(WithMinTLSVersion(tls.VersionTLS12))
+	if err != nil {
+		t.Fatalf("unexpected error when creating client - %s", err.Error())
+	}
+
+	transport, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatal("expected default client to have been constructed with an *http.Transport")
+	}
+
+	if transport.TLSClientConfig == nil || transport.TLSClientConfig.MinVersion != tls.VersionTLS12 {
+		t.Error("expected minimum TLS version to have been set on the transport")
+	}
+
+	custom := &http.Transport{}
+	c, _ = NewDefaultClient(WithTransport(custom), WithMinTLSVersion(tls.VersionTLS12))
+	if c.httpClient.Transport != custom {
+		t.Error("expected provided transport to have been used")
+	}
+	if custom.TLSClientConfig != nil {
+		t.Error("expected minimum TLS version to be ignored when a transport is provided")
+	}
+}
+
+func TestWithProxy(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, defaultBackendUrl, nil)
+
+	c, err := NewDefaultClient(WithProxy("http://proxy.example.com:3128"))
+	if err != nil {
+		t.Fatalf("unexpected error when creating client - %s", err.Error())
+	}
+
+	transport, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatal("expected default client to have been constructed with an *http.Transport")
+	}
+
+	proxyURL, err := transport.Proxy(req)
+	if err != nil {
+		t.Fatalf("unexpected error resolving proxy - %s", err.Error())
+	}
+	equals(t, "http://proxy.example.com:3128", proxyURL.String())
+
+	// the environment is respected by default
+	c, _ = NewDefaultClient()
+	if c.httpClient.Transport.(*http.Transport).Proxy == nil {
+		t.Error("expected proxy to be resolved from the environment by default")
+	}
+
+	for _, invalid := range []string{"proxy.example.com:3128", "://proxy", "http://"} {
+		if _, err := NewDefaultClient(WithProxy(invalid)); err == nil {
+			t.Errorf("expected error for invalid proxy url %s", invalid)
+		}
+	}
+
+	custom := &http.Client{}
+	c, _ = NewClient(defaultBackendUrl, custom, WithProxy("http://proxy.example.com:3128"))
+	if c.httpClient != custom || custom.Transport != nil {
+		t.Error("expected proxy to be ignored when an http.Client is provided")
+	}
+}
+
+func TestConvertXmlToUsageReport(t *testing.T) {
+	for name, period := range granularityMap {
+		report, err := convertXmlToUsageReport(internal.UsageReportXML{
+			Metric:       "hits",
+			Period:       name,
+			PeriodStart:  "2019-02-22 14:32:00 +0000",
+			PeriodEnd:    "2019-02-22 14:32:01 +0000",
+			MaxValue:     4,
+			CurrentValue: 1,
+		})
+		if err != nil {
+			t.Errorf("unexpected error converting period %s", name)
+		}
+		equals(t, period, report.PeriodWindow.Period)
+		equals(t, name, report.PeriodWindow.Period.String())
+	}
+
+	const expectStart, expectEnd = int64(1550845920), int64(1550845980)
+	inputs := []struct {
+		name      string
+		period    string
+		start     string
+		end       string
+		expectErr bool
+	}{
+		{name: "Test RFC3339", period: "minute", start: "2019-02-22T14:32:00Z", end: "2019-02-22T15:33:00+01:00"},
+		{name: "Test Z suffix", period: "minute", start: "2019-02-22 14:32:00Z", end: "2019-02-22 14:33:00Z"},
+		{name: "Test fractional seconds", period: "minute", start: "2019-02-22 14:32:00.123 +0000", end: "2019-02-22T14:33:00.5Z"},
+		{name: "Test unknown format", period: "minute", start: "22/02/2019 14:32", end: "2019-02-22 14:33:00 +0000", expectErr: true},
+		{name: "Test missing timestamps", period: "minute", expectErr: true},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			report, err := convertXmlToUsageReport(internal.UsageReportXML{
+				Metric:      "hits",
+				Period:      input.period,
+				PeriodStart: input.start,
+				PeriodEnd:   input.end,
+			})
+			if input.expectErr {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error - %s", err.Error())
+			}
+			equals(t, api.PeriodWindow{Period: api.Minute, Start: expectStart, End: expectEnd}, report.PeriodWindow)
+		})
+	}
+
+	// eternity windows are unbounded so are returned without timestamps
+	report, err := convertXmlToUsageReport(internal.UsageReportXML{Metric: "hits", Period: "eternity", MaxValue: 4})
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, api.UsageReport{PeriodWindow: api.PeriodWindow{Period: api.Eternity}, MaxValue: 4}, report)
+}
+
+func TestWithStrictTimeParsing(t *testing.T) {
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<status>
+  <authorized>true</authorized>
+  <plan>Basic</plan>
+  <usage_reports>
+    <usage_report metric="hits" period="minute">
+      <period_start>not-a-time</period_start>
+      <period_end>2019-02-22 14:33:00 +0000</period_end>
+      <max_value>4</max_value>
+      <current_value>1</current_value>
+    </usage_report>
+    <usage_report metric="hits" period="hour">
+      <period_start>2019-02-22T14:00:00Z</period_start>
+      <period_end>2019-02-22T15:00:00Z</period_end>
+      <max_value>10</max_value>
+      <current_value>1</current_value>
+    </usage_report>
+  </usage_reports>
+</status>`
+	httpClient := NewTestClient(testutil.RespondWith(testutil.NewResponse(http.StatusOK).WithXML(body)))
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}}},
+	}
+
+	// by default the invalid report is discarded
+	result, err := threeScaleTestClient(t, httpClient).Authorize(apiCall)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, 1, len(result.UsageReports["hits"]))
+	equals(t, api.Hour, result.UsageReports["hits"][0].PeriodWindow.Period)
+
+	c, err := NewClient(defaultBackendUrl, httpClient, WithStrictTimeParsing())
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	_, err = c.Authorize(apiCall)
+	if !errors.Is(err, threescale.ErrDecode) || !strings.Contains(err.Error(), "not-a-time") {
+		t.Errorf("expected decode error for invalid timestamp but got %v", err)
+	}
+}
+
+func TestWithDialOverride(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equals(t, "su1.3scale.net", r.Host)
+		equals(t, statusEndpoint, r.URL.Path)
+		w.Write([]byte(`{"status":"ok","version":{"backend":"2.96.2"}}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient("http://su1.3scale.net", nil, WithDialOverride("su1.3scale.net", server.Listener.Addr().String()))
+	if err != nil {
+		t.Fatalf("unexpected error when creating client - %s", err.Error())
+	}
+
+	version, err := c.GetVersion()
+	if err != nil {
+		t.Fatalf("expected request to have been dialed to the override address - %s", err.Error())
+	}
+	equals(t, "2.96.2", version)
+}
+
+func TestWithBackendAddr(t *testing.T) {
+	// the certificate of the test server is valid for example.com, so the handshake only succeeds
+	// if the TLS server name remains that of the backend URL
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equals(t, "example.com", r.Host)
+		equals(t, statusEndpoint, r.URL.Path)
+		w.Write([]byte(`{"status":"ok","version":{"backend":"2.96.2"}}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient("https://example.com", nil, WithBackendAddr(server.Listener.Addr().String()))
+	if err != nil {
+		t.Fatalf("unexpected error when creating client - %s", err.Error())
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	c.httpClient.Transport.(*http.Transport).TLSClientConfig = &tls.Config{RootCAs: pool}
+
+	version, err := c.GetVersion()
+	if err != nil {
+		t.Fatalf("expected request to have been dialed to the backend address - %s", err.Error())
+	}
+	equals(t, "2.96.2", version)
 }
 
 // ******
@@ -1166,6 +3806,10 @@ func TestCodeToStatusCode(t *testing.T) {
 			input:  "referrer_filter_invalid",
 			expect: http.StatusUnprocessableEntity,
 		},
+		{
+			input:  "unknown_code",
+			expect: 0,
+		},
 	}
 
 	for _, test := range tests {
@@ -1173,21 +3817,101 @@ func TestCodeToStatusCode(t *testing.T) {
 	}
 }
 
-// ******
+func BenchmarkAuthorize(b *testing.B) {
+	response := testutil.NewResponse(http.StatusOK).WithXML(fake.GetAuthSuccess())
+	c, err := NewClient(defaultBackendUrl, NewTestClient(testutil.RespondWith(response)))
+	if err != nil {
+		b.Fatalf("unexpected error - %s", err.Error())
+	}
 
-// *****
-// Mocking objects for HTTP tests
-type RoundTripFunc func(req *http.Request) *http.Response
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app", AppKey: "key"}, Metrics: api.Metrics{"hits": 1}}},
+	}
 
-func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
-	return f(req), nil
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Authorize(apiCall); err != nil {
+			b.Fatalf("unexpected error - %s", err.Error())
+		}
+	}
 }
 
-// Get a test client with transport overridden for mocking
-func NewTestClient(fn RoundTripFunc) *http.Client {
-	return &http.Client{
-		Transport: RoundTripFunc(fn),
+func BenchmarkWithSkipBodyParsing(b *testing.B) {
+	response := testutil.NewResponse(http.StatusOK).WithXML(fake.NewAuthSuccess().
+		WithUsageReport("hits", "minute", 100, 1).
+		WithUsageReport("hits", "hour", 1000, 1).
+		WithUsageReport("hits", "day", 10000, 1).
+		WithHierarchy("hits", "child_one", "child_two").
+		XML())
+	c, err := NewClient(defaultBackendUrl, NewTestClient(testutil.RespondWith(response)))
+	if err != nil {
+		b.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Extensions:   api.NewExtensions().WithHierarchy().Build(),
+		Transactions: []api.Transaction{{Params: api.Params{AppID: "app"}, Metrics: api.Metrics{"hits": 1}}},
+	}
+
+	for _, input := range []struct {
+		name    string
+		options []Option
+	}{{name: "parsed"}, {name: "skipped", options: []Option{WithSkipBodyParsing()}}} {
+		b.Run(input.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.AuthorizeWithOptions(apiCall, input.options...); err != nil {
+					b.Fatalf("unexpected error - %s", err.Error())
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRequestBuilder_Build(b *testing.B) {
+	c, err := NewClient(defaultBackendUrl, http.DefaultClient)
+	if err != nil {
+		b.Fatalf("unexpected error - %s", err.Error())
+	}
+	rb := c.requestBuilder()
+
+	transaction := api.Transaction{
+		Params:  api.Params{AppID: "app", AppKey: "key", Referrer: "example.com"},
+		Metrics: api.Metrics{"hits": 1, "other": 2, "another": 3},
+	}
+	apiCall := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{transaction, transaction, transaction},
+	}
+
+	for _, input := range []struct {
+		name string
+		kind kind
+	}{{name: "authorize", kind: auth}, {name: "report", kind: report}} {
+		b.Run(input.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := rb.build(context.TODO(), apiCall, c.backendURL, input.kind); err != nil {
+					b.Fatalf("unexpected error - %s", err.Error())
+				}
+			}
+		})
 	}
 }
 
 // ******
+
+// *****
+// Mocking objects for HTTP tests
+// Get a test client with transport overridden for mocking
+func NewTestClient(fn testutil.RoundTripFunc) *http.Client {
+	return testutil.NewRoundTripClient(fn)
+}
+
+// ******
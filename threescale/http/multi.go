@@ -0,0 +1,90 @@
+package http
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/3scale/3scale-go-client/threescale"
+	"github.com/3scale/3scale-go-client/threescale/api"
+)
+
+// maxReportMultiWorkers is the maximum number of reports sent concurrently by ReportMulti
+const maxReportMultiWorkers = 8
+
+// ReportMultiError is returned by ReportMulti when one or more of the reports could not be completed.
+// Errors holds the failure for each affected service. Reports for other services are unaffected.
+type ReportMultiError struct {
+	Errors map[api.Service]error
+}
+
+func (e *ReportMultiError) Error() string {
+	services := make([]string, 0, len(e.Errors))
+	for service := range e.Errors {
+		services = append(services, string(service))
+	}
+	sort.Strings(services)
+
+	failed := make([]string, len(services))
+	for i, service := range services {
+		failed[i] = fmt.Sprintf("service %s - %s", service, e.Errors[api.Service(service)].Error())
+	}
+	return fmt.Sprintf("failed to report for %d service(s) - [%s]", len(failed), strings.Join(failed, ", "))
+}
+
+// ReportMulti reports each of the provided requests concurrently, returning the result of each report keyed by
+// its service. Each service must appear at most once. A failure to report for one service does not prevent
+// the others from being reported - where any report fails, or is not accepted, a *ReportMultiError is returned
+// alongside the results, which holds the failure for each affected service.
+func (c *Client) ReportMulti(apiCalls []threescale.Request, options ...Option) (map[api.Service]*threescale.ReportResult, error) {
+	seen := make(map[api.Service]bool, len(apiCalls))
+	for _, apiCall := range apiCalls {
+		if seen[apiCall.Service] {
+			return nil, fmt.Errorf("%w - service %s provided more than once", threescale.ErrValidation, apiCall.Service)
+		}
+		seen[apiCall.Service] = true
+	}
+
+	workers := len(apiCalls)
+	if workers > maxReportMultiWorkers {
+		workers = maxReportMultiWorkers
+	}
+
+	var mutex sync.Mutex
+	results := make(map[api.Service]*threescale.ReportResult, len(apiCalls))
+	failures := make(map[api.Service]error)
+
+	queue := make(chan threescale.Request)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for apiCall := range queue {
+				result, err := c.ReportWithOptions(apiCall, options...)
+				if err == nil && !result.Accepted {
+					err = fmt.Errorf("report not accepted - %s", result.ErrorCode)
+				}
+
+				mutex.Lock()
+				results[apiCall.Service] = result
+				if err != nil {
+					failures[apiCall.Service] = err
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, apiCall := range apiCalls {
+		queue <- apiCall
+	}
+	close(queue)
+	wg.Wait()
+
+	if len(failures) > 0 {
+		return results, &ReportMultiError{Errors: failures}
+	}
+	return results, nil
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"testing"
+
+	"github.com/3scale/3scale-go-client/fake"
+	"github.com/3scale/3scale-go-client/threescale"
+	"github.com/3scale/3scale-go-client/threescale/api"
+	"github.com/3scale/3scale-go-client/threescale/testutil"
+)
+
+func TestClient_ReportMulti(t *testing.T) {
+	httpClient := NewTestClient(func(req *http.Request) *http.Response {
+		body, _ := ioutil.ReadAll(req.Body)
+		values, _ := url.ParseQuery(string(body))
+
+		switch values.Get(serviceIDKey) {
+		case "svc-denied":
+			return testutil.NewResponse(http.StatusForbidden).WithXML(fake.NewErrorResp("user_key_invalid", `user key "key" is invalid`)).Build()
+		case "svc-error":
+			return testutil.NewResponse(http.StatusServiceUnavailable).Build()
+		default:
+			return testutil.NewResponse(http.StatusAccepted).Build()
+		}
+	})
+	c := threeScaleTestClient(t, httpClient)
+
+	services := []api.Service{"svc-e", "svc-denied", "svc-a", "svc-error", "svc-c", "svc-b", "svc-d", "svc-f", "svc-g", "svc-h"}
+	apiCalls := make([]threescale.Request, len(services))
+	for i, service := range services {
+		apiCalls[i] = threescale.Request{
+			Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+			Service:      service,
+			Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}, Metrics: api.Metrics{"hits": 1}}},
+		}
+	}
+
+	var previousErr string
+	for attempt := 0; attempt < 5; attempt++ {
+		results, err := c.ReportMulti(apiCalls)
+
+		var multiErr *ReportMultiError
+		if !errors.As(err, &multiErr) {
+			t.Fatalf("expected ReportMultiError but got %v", err)
+		}
+
+		var keys []string
+		for service := range results {
+			keys = append(keys, string(service))
+		}
+		sort.Strings(keys)
+		equals(t, []string{"svc-a", "svc-b", "svc-c", "svc-d", "svc-denied", "svc-e", "svc-error", "svc-f", "svc-g", "svc-h"}, keys)
+
+		for _, service := range services {
+			switch service {
+			case "svc-denied":
+				equals(t, false, results[service].Accepted)
+				equals(t, "user_key_invalid", results[service].ErrorCode)
+			case "svc-error":
+				if !errors.Is(multiErr.Errors[service], threescale.ErrBackend5xx) {
+					t.Errorf("expected ErrBackend5xx but got %v", multiErr.Errors[service])
+				}
+			default:
+				equals(t, true, results[service].Accepted)
+			}
+		}
+		equals(t, 2, len(multiErr.Errors))
+
+		// the error is reported in the same order regardless of the order in which reports complete
+		if previousErr != "" {
+			equals(t, previousErr, err.Error())
+		}
+		previousErr = err.Error()
+	}
+
+	if _, err := c.ReportMulti(append(apiCalls, apiCalls[0])); !errors.Is(err, threescale.ErrValidation) {
+		t.Errorf("expected validation error for duplicate service but got %v", err)
+	}
+
+	results, err := c.ReportMulti(apiCalls[2:3])
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, 1, len(results))
+}
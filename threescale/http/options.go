@@ -2,13 +2,37 @@ package http
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
 	"time"
+
+	"github.com/3scale/3scale-go-client/threescale/api"
 )
 
 // InstrumentationCB provides a callback hook into the client at response time to provide information
-// about the underlying request to the remote host
+// about the underlying request to the remote host. The endpoint which was called is available from ctx
+// via EndpointFromContext.
 type InstrumentationCB func(ctx context.Context, hostName string, statusCode int, requestDuration time.Duration)
 
+// endpointContextKey is the key of the endpoint called within the context provided to the InstrumentationCB
+type endpointContextKey struct{}
+
+// withEndpoint returns a copy of ctx carrying the name of the endpoint called for the kind
+func withEndpoint(ctx context.Context, k kind) context.Context {
+	return context.WithValue(ctx, endpointContextKey{}, k.String())
+}
+
+// EndpointFromContext returns the name of the backend endpoint called from the context provided to the
+// InstrumentationCB - one of "authorize", "authrep", "report", "oauth_authorize" or "oauth_authrep".
+// Returns false where the context has not been provided by the client.
+func EndpointFromContext(ctx context.Context) (string, bool) {
+	endpoint, ok := ctx.Value(endpointContextKey{}).(string)
+	return endpoint, ok
+}
+
 // Option defines a callback function which is used to provide functional options to a request
 type Option func(*Options)
 
@@ -16,6 +40,29 @@ type Option func(*Options)
 type Options struct {
 	context           context.Context
 	instrumentationCB InstrumentationCB
+	maxBatchSize      int
+	traceContext      TraceContext
+	requestModifiers  []func(*http.Request)
+	compression       bool
+	// rejectionReasonHeader enables the rejection_reason_header extension for authorization calls
+	rejectionReasonHeader bool
+	// sortUsageReports sorts the usage reports for each metric by granularity, in the order given by sortAscending
+	sortUsageReports bool
+	sortAscending    bool
+	// skipBodyParsing determines the result of authorization calls from the response status, discarding the body
+	skipBodyParsing bool
+	// authorizeTimestamp sends the timestamp of the transaction on authorization calls
+	authorizeTimestamp bool
+	// idempotencyKey identifies a logical report so that retries can be deduplicated by backend
+	idempotencyKey string
+	// flatUsageHierarchy is used to compute parent metrics on the client when reporting with the flat_usage extension
+	flatUsageHierarchy api.Hierarchy
+	// asyncErrorHandler is called with any error encountered by ReportAsync
+	asyncErrorHandler func(error)
+	// synchronousInstrumentation runs the instrumentationCB inline rather than in a separate goroutine
+	synchronousInstrumentation bool
+	// backendURL overrides the backend of the Client for a single call
+	backendURL string
 }
 
 // WithContext wraps the http transaction to 3scale backend with the provided context
@@ -33,9 +80,339 @@ func WithInstrumentationCallback(callback InstrumentationCB) Option {
 	}
 }
 
+// WithSynchronousInstrumentation runs the callback provided via WithInstrumentationCallback in the calling goroutine,
+// guaranteeing that it has completed before the API call returns
+func WithSynchronousInstrumentation() Option {
+	return func(options *Options) {
+		options.synchronousInstrumentation = true
+	}
+}
+
+// WithBackendURL sends the request to the backend at backendURL in place of the backend of the Client, allowing a
+// single Client to be used with multiple backends. backendURL must be in one of the formats accepted by NewClient,
+// otherwise an error matching threescale.ErrHTTPBuild is returned. The hostname passed to the callback provided via
+// WithInstrumentationCallback is that of backendURL.
+func WithBackendURL(backendURL string) Option {
+	return func(options *Options) {
+		options.backendURL = backendURL
+	}
+}
+
+// WithTraceContext propagates the W3C trace context carried by ctx (see ContextWithTraceContext) to 3scale backend
+// by setting the 'traceparent' and 'tracestate' headers on the outbound request
+func WithTraceContext(ctx context.Context) Option {
+	return func(options *Options) {
+		if tc, ok := TraceContextFromContext(ctx); ok {
+			options.traceContext = tc
+		}
+	}
+}
+
+// WithRequestModifier allows the caller to modify the request to 3scale backend, for example to set custom headers.
+// The modifier is called after the request has been built and before it is sent. Modifiers are applied in
+// the order they are provided. A nil modifier is ignored.
+func WithRequestModifier(modifier func(*http.Request)) Option {
+	return func(options *Options) {
+		if modifier != nil {
+			options.requestModifiers = append(options.requestModifiers, modifier)
+		}
+	}
+}
+
+// WithCompression requests a gzip encoded response from 3scale backend by setting the 'Accept-Encoding' header,
+// decompressing the response before it is decoded. This reduces bandwidth for large responses such as usage reports.
+func WithCompression() Option {
+	return func(options *Options) {
+		options.compression = true
+	}
+}
+
+// WithRejectionReasonHeader enables the rejection_reason_header extension for authorization calls, instructing
+// backend to provide the reason an authorization has been denied in the '3scale-Rejection-Reason' header.
+// Where provided, the header is used to populate the ErrorCode of the result. This option is ignored by Report.
+func WithRejectionReasonHeader() Option {
+	return func(options *Options) {
+		options.rejectionReasonHeader = true
+	}
+}
+
+// WithSortedUsageReports sorts the usage reports returned for each metric by the granularity of their period.
+// When ascending is true, the most granular period (for example minute before hour) is first.
+// By default, usage reports are returned in the order provided by backend. This option is ignored by Report.
+func WithSortedUsageReports(ascending bool) Option {
+	return func(options *Options) {
+		options.sortUsageReports = true
+		options.sortAscending = ascending
+	}
+}
+
+// WithSkipBodyParsing determines the result of authorization calls from the status code of the response alone,
+// without decoding the body, much like the no_body extension but client side. Authorized is true only for a 200
+// response, with the ErrorCode populated from the '3scale-Rejection-Reason' header where provided - see
+// WithRejectionReasonHeader. UsageReports and Hierarchy are always empty in this mode, although RateLimits are
+// populated from the response headers when the limit_headers extension is enabled. This option is ignored by Report.
+func WithSkipBodyParsing() Option {
+	return func(options *Options) {
+		options.skipBodyParsing = true
+	}
+}
+
+// WithAuthorizeTimestamp sends the Timestamp of the transaction, where set, on Authorize and OauthAuthorize calls,
+// authorizing the request as of that time. This is useful for testing and replaying traffic, but is not supported by
+// all backends so must be enabled explicitly. AuthRep always sends the timestamp. This option is ignored by Report.
+func WithAuthorizeTimestamp() Option {
+	return func(options *Options) {
+		options.authorizeTimestamp = true
+	}
+}
+
+// WithIdempotencyKey sets the 'Idempotency-Key' header on Report requests, allowing backend to deduplicate a report
+// which is retried, avoiding double counting of usage. The same key must be used for every attempt of a logical
+// report and must be unique across logical reports - see NewIdempotencyKey. Where a report is split into multiple
+// requests (see WithMaxBatchSize), each request is identified by the key suffixed with the index of its chunk.
+// This requires support in backend - the header is ignored otherwise. This option is ignored by all other calls.
+func WithIdempotencyKey(key string) Option {
+	return func(options *Options) {
+		options.idempotencyKey = key
+	}
+}
+
+// NewIdempotencyKey returns a random key suitable for use with WithIdempotencyKey
+func NewIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate idempotency key - %s", err.Error())
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// WithAsyncErrorHandler sets a handler which is called, from a separate goroutine, with any error encountered by
+// ReportAsync, including the rejection of the report by backend. This option is ignored by all other calls.
+func WithAsyncErrorHandler(handler func(error)) Option {
+	return func(options *Options) {
+		options.asyncErrorHandler = handler
+	}
+}
+
+// WithFlatUsageHierarchy enables the flat_usage extension for Report calls and uses the provided hierarchy, typically
+// returned by a prior authorization with the hierarchy extension enabled, to expand the usage of child metrics into
+// their parents before the request is built. With flat_usage enabled, backend does not compute these relationships
+// so this responsibility falls to the client. This option is ignored by all other calls.
+// The flat_usage extension is supported by backend versions >= 2.8 - see GetVersion.
+func WithFlatUsageHierarchy(hierarchy api.Hierarchy) Option {
+	return func(options *Options) {
+		options.flatUsageHierarchy = hierarchy
+	}
+}
+
+// WithMaxBatchSize sets the maximum number of transactions which will be sent to 3scale in a single report request.
+// Where a report contains more transactions than this value, it will be split into chunks and reported sequentially.
+// Reporting stops at the first chunk which is not accepted. As for a report sent in a single request, a chunk which
+// exceeds limits is a denial, identified by ReportResult.IsLimitExceeded, rather than an error.
+// Values less than 1 are ignored and the default batch size is used.
+func WithMaxBatchSize(n int) Option {
+	return func(options *Options) {
+		if n > 0 {
+			options.maxBatchSize = n
+		}
+	}
+}
+
+// ClientOption defines a callback function which is used to provide functional options to a Client at construction
+type ClientOption func(*ClientOptions)
+
+// ClientOptions to provide optional behaviour to a Client for its lifetime.
+// Options related to the transport are only applied when the Client constructs its own http.Client.
+type ClientOptions struct {
+	transport                http.RoundTripper
+	minTLSVersion            uint16
+	reportOnlyPositiveDeltas bool
+	includeZeroMetrics       bool
+	dialOverrides            map[string]string
+	userAgent                string
+	clientInfo               string
+	defaultExtensions        api.Extensions
+	maxResponseBytes         int64
+	strictTimeParsing        bool
+	maxAsyncReports          int
+	dump                     io.Writer
+	responseFormat           ResponseFormat
+	proxyURL                 string
+	backendAddr              string
+}
+
+// WithTransport sets the transport used by the http.Client constructed for the Client.
+// When provided, other transport related options such as WithMinTLSVersion are ignored.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(options *ClientOptions) {
+		options.transport = transport
+	}
+}
+
+// WithMinTLSVersion sets the minimum TLS version (for example tls.VersionTLS12) accepted by the transport
+// constructed for the Client. This option is ignored when WithTransport is used.
+func WithMinTLSVersion(v uint16) ClientOption {
+	return func(options *ClientOptions) {
+		options.minTLSVersion = v
+	}
+}
+
+// WithReportOnlyPositiveDeltas ensures that only metrics with a positive value are sent to 3scale when reporting usage
+// via Report or AuthRep. Any metric with a zero or negative value, such as a delta computed across a period rollover,
+// is discarded before the request is built.
+func WithReportOnlyPositiveDeltas() ClientOption {
+	return func(options *ClientOptions) {
+		options.reportOnlyPositiveDeltas = true
+	}
+}
+
+// WithIncludeZeroMetrics sends metrics with a zero value to 3scale. By default, zero valued metrics are omitted from
+// the request as they have no effect in backend. This option has no effect for Report and AuthRep when used in
+// conjunction with WithReportOnlyPositiveDeltas.
+func WithIncludeZeroMetrics() ClientOption {
+	return func(options *ClientOptions) {
+		options.includeZeroMetrics = true
+	}
+}
+
+// WithProxy routes requests to backend via the HTTP proxy at proxyURL, for example "http://proxy.example.com:3128",
+// in place of any proxy configured by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables, which are
+// otherwise respected. NewClient returns an error should proxyURL be invalid.
+// This option is ignored when WithTransport is used, or when an http.Client is provided to NewClient.
+func WithProxy(proxyURL string) ClientOption {
+	return func(options *ClientOptions) {
+		options.proxyURL = proxyURL
+	}
+}
+
+// WithBackendAddr dials addr, such as the IP of a specific backend pod, for all connections to backend, bypassing DNS.
+// The request URL, Host header and TLS server name remain those of the backend URL provided to NewClient.
+// addr may omit the port, in which case the port of the backend URL is used.
+// This option is ignored when WithTransport is used. See WithDialOverride to override the address of any host.
+func WithBackendAddr(addr string) ClientOption {
+	return func(options *ClientOptions) {
+		options.backendAddr = addr
+	}
+}
+
+// WithDialOverride rewrites the address dialed for connections to host, to addr, leaving the request URL and
+// TLS server name untouched. addr may omit the port, in which case the port of the original address is used.
+// This is useful for pointing a hostname at a local backend while preserving its certificate expectations.
+// This option is ignored when WithTransport is used.
+func WithDialOverride(host string, addr string) ClientOption {
+	return func(options *ClientOptions) {
+		if options.dialOverrides == nil {
+			options.dialOverrides = make(map[string]string)
+		}
+		options.dialOverrides[host] = addr
+	}
+}
+
+// WithUserAgent overrides the default User-Agent header (see DefaultUserAgent) sent on all requests made by the Client
+func WithUserAgent(userAgent string) ClientOption {
+	return func(options *ClientOptions) {
+		options.userAgent = userAgent
+	}
+}
+
+// WithClientMetadata identifies the integration making calls to 3scale by setting the '3scale-client-info' header,
+// in the form 'name/version', on all requests made by the Client. Unlike the User-Agent header, this header is
+// not expected to be rewritten by intermediate proxies.
+func WithClientMetadata(name string, version string) ClientOption {
+	return func(options *ClientOptions) {
+		options.clientInfo = name + "/" + version
+	}
+}
+
+// WithDefaultExtensions sets extensions which are sent on all Authorize, AuthRep and Report requests made by the Client.
+// These are merged with the extensions of each request, with the values provided by the request taking precedence.
+func WithDefaultExtensions(extensions api.Extensions) ClientOption {
+	return func(options *ClientOptions) {
+		options.defaultExtensions = make(api.Extensions, len(extensions))
+		for k, v := range extensions {
+			options.defaultExtensions[k] = v
+		}
+	}
+}
+
+// WithMaxResponseBytes limits the size of a response body which will be read from 3scale, guarding against excessive
+// allocations should backend, or an intermediate proxy, misbehave. Where a body exceeds this size, an error matching
+// threescale.ErrDecode is returned. Values less than 1 are ignored and a default of 4MiB is used.
+func WithMaxResponseBytes(n int64) ClientOption {
+	return func(options *ClientOptions) {
+		if n > 0 {
+			options.maxResponseBytes = n
+		}
+	}
+}
+
+// WithStrictTimeParsing returns an error matching threescale.ErrDecode from authorization calls where the window of a
+// usage report cannot be parsed. By default, such usage reports are discarded and the remainder of the result returned.
+func WithStrictTimeParsing() ClientOption {
+	return func(options *ClientOptions) {
+		options.strictTimeParsing = true
+	}
+}
+
+// WithMaxAsyncReports sets the maximum number of reports which may be in flight via ReportAsync, beyond which
+// ReportAsync returns ErrAsyncReportLimit. Values less than 1 are ignored and a default of 100 is used.
+func WithMaxAsyncReports(n int) ClientOption {
+	return func(options *ClientOptions) {
+		if n > 0 {
+			options.maxAsyncReports = n
+		}
+	}
+}
+
+// WithRequestResponseDump writes each request sent to backend, and the response received, to w in HTTP/1.x wire format,
+// including bodies. This is intended as a debugging aid - response bodies are read in full into memory, and requests
+// include credentials, so it should not be enabled in production. Writes to w are serialised by the Client.
+func WithRequestResponseDump(w io.Writer) ClientOption {
+	return func(options *ClientOptions) {
+		options.dump = w
+	}
+}
+
+// ResponseFormat is the format in which responses are requested from 3scale backend
+type ResponseFormat int
+
+const (
+	// XML is the default response format, supported by all backend endpoints
+	XML ResponseFormat = iota
+	// JSON requests JSON responses. Responses which backend returns as XML regardless continue to be decoded as XML.
+	JSON
+)
+
+// mediaType returns the value of the Accept header used to request the format
+func (f ResponseFormat) mediaType() string {
+	if f == JSON {
+		return "application/json"
+	}
+	return "application/xml"
+}
+
+// WithResponseFormat sets the format in which responses from the authorization and report endpoints are requested,
+// via the Accept header, and decoded. XML is used by default.
+func WithResponseFormat(format ResponseFormat) ClientOption {
+	return func(options *ClientOptions) {
+		options.responseFormat = format
+	}
+}
+
+// newClientOptions for a Client
+func newClientOptions(opts ...ClientOption) *ClientOptions {
+	options := &ClientOptions{}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return options
+}
+
 // newOptions for 3scale backend
 func newOptions(opts ...Option) *Options {
-	options := &Options{context: context.TODO()}
+	options := &Options{context: context.TODO(), maxBatchSize: defaultMaxBatchSize}
 
 	for _, opt := range opts {
 		opt(options)
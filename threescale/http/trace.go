@@ -0,0 +1,50 @@
+package http
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	// traceParentHeaderKey is the W3C trace context header identifying the incoming request in a tracing system
+	traceParentHeaderKey = "traceparent"
+	// traceStateHeaderKey is the W3C trace context header carrying vendor specific trace identification data
+	traceStateHeaderKey = "tracestate"
+)
+
+type traceContextKey struct{}
+
+// TraceContext holds the W3C trace context values which are propagated to 3scale backend
+// See https://www.w3.org/TR/trace-context/
+type TraceContext struct {
+	// TraceParent is the value of the 'traceparent' header, for example 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+	TraceParent string
+	// TraceState is the optional value of the 'tracestate' header
+	TraceState string
+}
+
+// ContextWithTraceContext returns a copy of ctx which carries the provided TraceContext
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceContextFromContext returns the TraceContext carried by ctx, if any
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	if ctx == nil {
+		return TraceContext{}, false
+	}
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// setHeaders injects the trace context propagation headers into the request
+func (tc TraceContext) setHeaders(req *http.Request) {
+	if tc.TraceParent == "" {
+		return
+	}
+
+	req.Header.Set(traceParentHeaderKey, tc.TraceParent)
+	if tc.TraceState != "" {
+		req.Header.Set(traceStateHeaderKey, tc.TraceState)
+	}
+}
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// TransportConfig provides the common knobs required to configure a transport for communicating with 3scale backend,
+// such as mutual TLS against an on-premise backend behind a private certificate authority.
+type TransportConfig struct {
+	// CertFile is the path to a PEM encoded client certificate presented to backend for mutual TLS.
+	// Must be provided along with KeyFile.
+	CertFile string
+	// KeyFile is the path to the PEM encoded private key for CertFile
+	KeyFile string
+	// CAFile is the path to a PEM encoded bundle of CA certificates used to verify backend,
+	// replacing the system certificate pool
+	CAFile string
+	// InsecureSkipVerify disables verification of the certificate presented by backend - DO NOT use in production
+	InsecureSkipVerify bool
+	// MaxIdleConns controls the maximum number of idle connections across all hosts. Zero uses the default.
+	MaxIdleConns int
+	// IdleConnTimeout is the maximum amount of time an idle connection will remain idle before closing itself.
+	// Zero uses the default.
+	IdleConnTimeout time.Duration
+}
+
+// NewTransport returns a transport, based on the default transport, configured using the provided TransportConfig
+func NewTransport(config TransportConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		if config.CertFile == "" || config.KeyFile == "" {
+			return nil, fmt.Errorf("both certificate and key files must be provided for client authentication")
+		}
+
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate - %s", err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if config.CAFile != "" {
+		caBundle, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle - %s", err.Error())
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("failed to parse any certificates from CA bundle %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if config.MaxIdleConns > 0 {
+		transport.MaxIdleConns = config.MaxIdleConns
+	}
+
+	if config.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = config.IdleConnTimeout
+	}
+
+	return transport, nil
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewTransport(t *testing.T) {
+	transport, err := NewTransport(TransportConfig{
+		InsecureSkipVerify: true,
+		MaxIdleConns:       5,
+		IdleConnTimeout:    time.Second,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	equals(t, true, transport.TLSClientConfig.InsecureSkipVerify)
+	equals(t, 5, transport.MaxIdleConns)
+	equals(t, time.Second, transport.IdleConnTimeout)
+
+	if _, err := NewTransport(TransportConfig{CertFile: "cert.pem"}); err == nil {
+		t.Error("expected error when key file is not provided")
+	}
+
+	if _, err := NewTransport(TransportConfig{CAFile: "does-not-exist.pem"}); err == nil {
+		t.Error("expected error when CA file does not exist")
+	}
+}
+
+func TestNewClientWithTransport(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			t.Error("expected client certificate to have been presented")
+		}
+		w.Write([]byte(`{"status":"ok","version":{"backend":"2.96.2"}}`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "transport")
+	if err != nil {
+		t.Fatalf("failed to create temp dir - %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// the servers certificate doubles as both the CA bundle and the client certificate
+	serverCert := server.TLS.Certificates[0]
+	key, err := x509.MarshalPKCS8PrivateKey(serverCert.PrivateKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key - %s", err.Error())
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writePEM(t, certFile, "CERTIFICATE", serverCert.Certificate[0])
+	writePEM(t, keyFile, "PRIVATE KEY", key)
+
+	transport, err := NewTransport(TransportConfig{CertFile: certFile, KeyFile: keyFile, CAFile: certFile})
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	c, err := NewClientWithTransport(server.URL, transport)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	version, err := c.GetVersion()
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	equals(t, "2.96.2", version)
+}
+
+func writePEM(t *testing.T, path string, blockType string, bytes []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bytes})
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write %s - %s", path, err.Error())
+	}
+}
@@ -0,0 +1,42 @@
+package http
+
+import "runtime/debug"
+
+// modulePath is the path of the module which provides the client library
+const modulePath = "github.com/3scale/3scale-go-client"
+
+// devVersion is the version reported where the version of the module cannot be determined,
+// for example when built from within the module itself
+const devVersion = "dev"
+
+var (
+	// Version of the client library, taken from the build information of the binary in which it is a dependency.
+	// This is the version of the module, for example "v1.2.3", as resolved by the go command, or "dev" where it
+	// cannot be determined.
+	Version = moduleVersion(debug.ReadBuildInfo())
+	// DefaultUserAgent is sent as the User-Agent header on all requests unless overridden with WithUserAgent
+	DefaultUserAgent = "3scale-go-client/" + Version
+)
+
+// moduleVersion returns the version of the client library module from the build information, following
+// any replacement. Local replacements and development builds have no version so devVersion is returned.
+func moduleVersion(info *debug.BuildInfo, ok bool) string {
+	if !ok || info == nil {
+		return devVersion
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path != modulePath {
+			continue
+		}
+
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+
+		if dep.Version != "" && dep.Version != "(devel)" {
+			return dep.Version
+		}
+	}
+	return devVersion
+}
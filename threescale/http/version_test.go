@@ -0,0 +1,59 @@
+package http
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestModuleVersion(t *testing.T) {
+	inputs := []struct {
+		name   string
+		info   *debug.BuildInfo
+		ok     bool
+		expect string
+	}{
+		{
+			name:   "Test build information unavailable",
+			expect: devVersion,
+		},
+		{
+			name: "Test version of dependency",
+			info: &debug.BuildInfo{Deps: []*debug.Module{
+				{Path: "example.com/other", Version: "v0.0.1"},
+				{Path: modulePath, Version: "v1.2.3"},
+			}},
+			ok:     true,
+			expect: "v1.2.3",
+		},
+		{
+			name: "Test version of replacement",
+			info: &debug.BuildInfo{Deps: []*debug.Module{
+				{Path: modulePath, Version: "v1.2.3", Replace: &debug.Module{Path: "example.com/fork", Version: "v1.2.4"}},
+			}},
+			ok:     true,
+			expect: "v1.2.4",
+		},
+		{
+			name: "Test local replacement",
+			info: &debug.BuildInfo{Deps: []*debug.Module{
+				{Path: modulePath, Version: "v1.2.3", Replace: &debug.Module{Path: "../3scale-go-client"}},
+			}},
+			ok:     true,
+			expect: devVersion,
+		},
+		{
+			name:   "Test built from within the module",
+			info:   &debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "(devel)"}},
+			ok:     true,
+			expect: devVersion,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			equals(t, input.expect, moduleVersion(input.info, input.ok))
+		})
+	}
+
+	equals(t, "3scale-go-client/"+Version, DefaultUserAgent)
+}
@@ -7,3 +7,34 @@ type StatusResponse struct {
 		Backend string `json:"backend"`
 	} `json:"version"`
 }
+
+// AuthResponseJSON formatted response from backend API for Authorize and AuthRep when JSON has been requested.
+// Error is set in place of the remaining fields for failures which are not reported as a denied authorization.
+type AuthResponseJSON struct {
+	Authorized   bool                `json:"authorized"`
+	Reason       string              `json:"reason,omitempty"`
+	Hierarchy    map[string][]string `json:"hierarchy,omitempty"`
+	UsageReports []UsageReportJSON   `json:"usage_reports,omitempty"`
+	Error        *ErrorJSON          `json:"error,omitempty"`
+}
+
+// UsageReportJSON captures the JSON response for rate limiting details
+type UsageReportJSON struct {
+	Metric       string `json:"metric"`
+	Period       string `json:"period"`
+	PeriodStart  string `json:"period_start,omitempty"`
+	PeriodEnd    string `json:"period_end,omitempty"`
+	MaxValue     int    `json:"max_value"`
+	CurrentValue int    `json:"current_value"`
+}
+
+// ErrorJSON captures the error code and message returned by backend
+type ErrorJSON struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ReportErrorJSON captures the JSON response from Report endpoint when not status 202
+type ReportErrorJSON struct {
+	Error ErrorJSON `json:"error"`
+}
@@ -6,6 +6,8 @@ import (
 
 // AuthResponseXML formatted response from backend API for Authorize and AuthRep
 type AuthResponseXML struct {
+	// XMLName is the root element, which is "error" rather than "status" for some failures
+	XMLName      xml.Name
 	Name         xml.Name     `xml:",any"`
 	Authorized   bool         `xml:"authorized,omitempty"`
 	Reason       string       `xml:"reason,omitempty"`
@@ -18,10 +20,13 @@ type AuthResponseXML struct {
 
 // HierarchyXML encapsulates the return value when using "hierarchy" extension
 type HierarchyXML struct {
-	Metric []struct {
-		Name     string `xml:"name,attr"`
-		Children string `xml:"children,attr"`
-	} `xml:"metric"`
+	Metric []HierarchyMetricXML `xml:"metric"`
+}
+
+// HierarchyMetricXML holds the space separated children of a metric within the hierarchy
+type HierarchyMetricXML struct {
+	Name     string `xml:"name,attr"`
+	Children string `xml:"children,attr"`
 }
 
 // UsageReportXML captures the XML response for rate limiting details
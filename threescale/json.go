@@ -0,0 +1,51 @@
+package threescale
+
+import (
+	"encoding/json"
+
+	"github.com/3scale/3scale-go-client/threescale/api"
+)
+
+// authorizeResultJSON is the stable JSON schema for an AuthorizeResult.
+// Implementation specific fields, such as RawResponse, are intentionally omitted.
+type authorizeResultJSON struct {
+	Authorized      bool             `json:"authorized"`
+	ErrorCode       string           `json:"error_code,omitempty"`
+	RejectionReason string           `json:"rejection_reason,omitempty"`
+	UsageReports    api.UsageReports `json:"usage_reports,omitempty"`
+	Hierarchy       api.Hierarchy    `json:"hierarchy,omitempty"`
+	RateLimits      *api.RateLimits  `json:"rate_limits,omitempty"`
+}
+
+// MarshalJSON provides a stable JSON representation of the AuthorizeResult, suitable for logging.
+// RawResponse, RequestURL and Latency are not included in the output.
+func (ar AuthorizeResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(authorizeResultJSON{
+		Authorized:      ar.Authorized,
+		ErrorCode:       ar.ErrorCode,
+		RejectionReason: ar.RejectionReason,
+		UsageReports:    ar.UsageReports,
+		Hierarchy:       ar.Hierarchy,
+		RateLimits:      ar.RateLimits,
+	})
+}
+
+// UnmarshalJSON populates the AuthorizeResult from the representation produced by MarshalJSON
+func (ar *AuthorizeResult) UnmarshalJSON(data []byte) error {
+	var result authorizeResultJSON
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+
+	*ar = AuthorizeResult{
+		Authorized:      result.Authorized,
+		ErrorCode:       result.ErrorCode,
+		RejectionReason: result.RejectionReason,
+		UsageReports:    result.UsageReports,
+		AuthorizeExtensions: AuthorizeExtensions{
+			Hierarchy:  result.Hierarchy,
+			RateLimits: result.RateLimits,
+		},
+	}
+	return nil
+}
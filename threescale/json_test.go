@@ -0,0 +1,59 @@
+package threescale
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/3scale/3scale-go-client/threescale/api"
+)
+
+func TestAuthorizeResult_MarshalJSON(t *testing.T) {
+	result := AuthorizeResult{
+		Authorized:      false,
+		ErrorCode:       "limits_exceeded",
+		RejectionReason: "usage limits are exceeded",
+		UsageReports: api.UsageReports{
+			"hits": []api.UsageReport{
+				{
+					PeriodWindow: api.PeriodWindow{Period: api.Minute, Start: 1550845920, End: 1550845980},
+					MaxValue:     4,
+					CurrentValue: 4,
+				},
+			},
+		},
+		RawResponse: &http.Response{StatusCode: http.StatusConflict},
+		AuthorizeExtensions: AuthorizeExtensions{
+			Hierarchy:  api.Hierarchy{"hits": []string{"example"}},
+			RateLimits: &api.RateLimits{LimitRemaining: 0, LimitReset: 20},
+		},
+	}
+
+	got, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	golden, err := ioutil.ReadFile(filepath.Join("testdata", "authorize_result.golden.json"))
+	if err != nil {
+		t.Fatalf("failed to read golden file - %s", err.Error())
+	}
+
+	if !bytes.Equal(bytes.TrimSpace(golden), got) {
+		t.Errorf("unexpected JSON output, wanted\n%s\nbut got\n%s", golden, got)
+	}
+
+	var roundTrip AuthorizeResult
+	if err := json.Unmarshal(got, &roundTrip); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	result.RawResponse = nil
+	if !reflect.DeepEqual(result, roundTrip) {
+		t.Errorf("expected round trip to produce %v but got %v", result, roundTrip)
+	}
+}
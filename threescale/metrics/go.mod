@@ -0,0 +1,10 @@
+module github.com/3scale/3scale-go-client/threescale/metrics
+
+go 1.13
+
+require (
+	github.com/3scale/3scale-go-client v0.0.0-00010101000000-000000000000
+	github.com/prometheus/client_golang v1.7.1
+)
+
+replace github.com/3scale/3scale-go-client => ../..
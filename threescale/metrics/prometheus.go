@@ -0,0 +1,82 @@
+// Package metrics provides ready-made collectors for the data made available to the instrumentation callback
+// of the threescale/http client. It is kept separate from the client so that applications which do not use it
+// do not depend upon the Prometheus client library.
+//
+// A PrometheusCollector must be registered before use, with its Callback provided to each request:
+//
+//	collector := metrics.NewPrometheusCollector("gateway")
+//	prometheus.MustRegister(collector)
+//
+//	client.AuthorizeWithOptions(request, http.WithInstrumentationCallback(collector.Callback))
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/3scale/3scale-go-client/threescale/http"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subsystem = "threescale_backend"
+
+	hostLabel     = "host"
+	endpointLabel = "endpoint"
+	statusLabel   = "status"
+
+	unknownEndpoint = "unknown"
+)
+
+// PrometheusCollector records the number of requests made to 3scale backend, labelled by host, endpoint and
+// status code, alongside a histogram of their latency by host and endpoint. PrometheusCollector implements
+// prometheus.Collector.
+type PrometheusCollector struct {
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+// NewPrometheusCollector returns a PrometheusCollector whose metrics are prefixed with the provided namespace,
+// which may be empty. The latency histogram uses the default Prometheus buckets.
+func NewPrometheusCollector(namespace string) *PrometheusCollector {
+	return &PrometheusCollector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "requests_total",
+			Help:      "Total number of requests made to 3scale backend",
+		}, []string{hostLabel, endpointLabel, statusLabel}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of requests made to 3scale backend",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{hostLabel, endpointLabel}),
+	}
+}
+
+// Callback records the details of a request to 3scale backend. Its signature matches http.InstrumentationCB
+// so it can be provided via http.WithInstrumentationCallback. The endpoint is read from ctx via
+// http.EndpointFromContext and recorded as "unknown" when absent. Callback is safe for concurrent use.
+func (pc *PrometheusCollector) Callback(ctx context.Context, hostName string, statusCode int, requestDuration time.Duration) {
+	endpoint, ok := http.EndpointFromContext(ctx)
+	if !ok {
+		endpoint = unknownEndpoint
+	}
+	pc.requests.WithLabelValues(hostName, endpoint, strconv.Itoa(statusCode)).Inc()
+	pc.latency.WithLabelValues(hostName, endpoint).Observe(requestDuration.Seconds())
+}
+
+// Describe implements prometheus.Collector
+func (pc *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
+	pc.requests.Describe(ch)
+	pc.latency.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (pc *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
+	pc.requests.Collect(ch)
+	pc.latency.Collect(ch)
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-go-client/fake"
+	"github.com/3scale/3scale-go-client/threescale"
+	"github.com/3scale/3scale-go-client/threescale/api"
+	client "github.com/3scale/3scale-go-client/threescale/http"
+	"github.com/3scale/3scale-go-client/threescale/testutil"
+	"github.com/prometheus/client_golang/prometheus"
+	prometheustestutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// ensure the callback can be provided as an option to the client
+var _ client.InstrumentationCB = NewPrometheusCollector("").Callback
+
+func TestPrometheusCollector(t *testing.T) {
+	collector := NewPrometheusCollector("test")
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	httpClient := testutil.NewRoundTripClient(func(req *http.Request) *http.Response {
+		if strings.HasSuffix(req.URL.Path, "/authrep.xml") {
+			return testutil.NewResponse(http.StatusConflict).WithXML(fake.GetLimitExceededResp()).Build()
+		}
+		return testutil.NewResponse(http.StatusOK).WithXML(fake.GetAuthSuccess()).Build()
+	})
+
+	c, err := client.NewClient("https://su1.3scale.net", httpClient)
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	request := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "any"},
+		Service:      "test",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "any"}}},
+	}
+	options := []client.Option{
+		client.WithInstrumentationCallback(collector.Callback),
+		client.WithSynchronousInstrumentation(),
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.AuthorizeWithOptions(request, options...); err != nil {
+			t.Fatalf("unexpected error - %s", err.Error())
+		}
+	}
+	if _, err := c.AuthRepWithOptions(request, options...); err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+	collector.Callback(context.TODO(), "su1.3scale.net", http.StatusOK, 10*time.Millisecond)
+
+	expect := `
+# HELP test_threescale_backend_requests_total Total number of requests made to 3scale backend
+# TYPE test_threescale_backend_requests_total counter
+test_threescale_backend_requests_total{endpoint="authorize",host="su1.3scale.net",status="200"} 2
+test_threescale_backend_requests_total{endpoint="authrep",host="su1.3scale.net",status="409"} 1
+test_threescale_backend_requests_total{endpoint="unknown",host="su1.3scale.net",status="200"} 1
+`
+	if err := prometheustestutil.GatherAndCompare(registry, strings.NewReader(expect), "test_threescale_backend_requests_total"); err != nil {
+		t.Errorf("unexpected request count - %s", err.Error())
+	}
+
+	if count := prometheustestutil.CollectAndCount(collector.latency); count != 3 {
+		t.Errorf("expected a latency histogram per endpoint but got %d", count)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("unexpected error - %s", err.Error())
+	}
+
+	for _, family := range families {
+		if family.GetName() != "test_threescale_backend_request_duration_seconds" {
+			continue
+		}
+
+		var observations uint64
+		for _, metric := range family.GetMetric() {
+			observations += metric.GetHistogram().GetSampleCount()
+		}
+		if observations != 4 {
+			t.Errorf("expected 4 latency observations but got %d", observations)
+		}
+		return
+	}
+	t.Errorf("expected latency histogram to be gathered")
+}
@@ -0,0 +1,125 @@
+// Package mock provides a threescale.Client implementation which can be used to inject
+// deterministic responses in tests, without the need to mock the underlying HTTP layer.
+package mock
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/3scale/3scale-go-client/threescale"
+)
+
+// ensure MockClient satisfies the threescale.Client interface
+var _ threescale.Client = (*MockClient)(nil)
+
+const (
+	// MethodAuthorize is recorded for calls to Authorize
+	MethodAuthorize = "Authorize"
+	// MethodAuthRep is recorded for calls to AuthRep
+	MethodAuthRep = "AuthRep"
+	// MethodOauthAuthorize is recorded for calls to OauthAuthorize
+	MethodOauthAuthorize = "OauthAuthorize"
+	// MethodOauthAuthRep is recorded for calls to OauthAuthRep
+	MethodOauthAuthRep = "OauthAuthRep"
+	// MethodReport is recorded for calls to Report
+	MethodReport = "Report"
+)
+
+// Call records a single call made to the MockClient
+type Call struct {
+	// Method is the name of the method called on the MockClient
+	Method  string
+	Request threescale.Request
+}
+
+// MockClient implements threescale.Client by delegating to the configured function fields.
+// Calling a method whose function field is nil returns an error.
+// Calls are recorded and can be inspected via Calls. MockClient is safe for concurrent use.
+type MockClient struct {
+	AuthorizeFunc      func(request threescale.Request) (*threescale.AuthorizeResult, error)
+	AuthRepFunc        func(request threescale.Request) (*threescale.AuthorizeResult, error)
+	OauthAuthorizeFunc func(request threescale.Request) (*threescale.AuthorizeResult, error)
+	OauthAuthRepFunc   func(request threescale.Request) (*threescale.AuthorizeResult, error)
+	ReportFunc         func(request threescale.Request) (*threescale.ReportResult, error)
+	// Peer is returned by GetPeer
+	Peer string
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Authorize records the call and delegates to AuthorizeFunc
+func (m *MockClient) Authorize(request threescale.Request) (*threescale.AuthorizeResult, error) {
+	m.record(MethodAuthorize, request)
+	return m.doAuth(MethodAuthorize, m.AuthorizeFunc, request)
+}
+
+// AuthRep records the call and delegates to AuthRepFunc
+func (m *MockClient) AuthRep(request threescale.Request) (*threescale.AuthorizeResult, error) {
+	m.record(MethodAuthRep, request)
+	return m.doAuth(MethodAuthRep, m.AuthRepFunc, request)
+}
+
+// OauthAuthorize records the call and delegates to OauthAuthorizeFunc
+func (m *MockClient) OauthAuthorize(request threescale.Request) (*threescale.AuthorizeResult, error) {
+	m.record(MethodOauthAuthorize, request)
+	return m.doAuth(MethodOauthAuthorize, m.OauthAuthorizeFunc, request)
+}
+
+// OauthAuthRep records the call and delegates to OauthAuthRepFunc
+func (m *MockClient) OauthAuthRep(request threescale.Request) (*threescale.AuthorizeResult, error) {
+	m.record(MethodOauthAuthRep, request)
+	return m.doAuth(MethodOauthAuthRep, m.OauthAuthRepFunc, request)
+}
+
+// Report records the call and delegates to ReportFunc
+func (m *MockClient) Report(request threescale.Request) (*threescale.ReportResult, error) {
+	m.record(MethodReport, request)
+	if m.ReportFunc == nil {
+		return nil, notConfigured(MethodReport)
+	}
+	return m.ReportFunc(request)
+}
+
+// GetPeer returns the configured Peer
+func (m *MockClient) GetPeer() string {
+	return m.Peer
+}
+
+// Calls returns a copy of the calls made to the MockClient in the order they were made
+func (m *MockClient) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	calls := make([]Call, len(m.calls))
+	copy(calls, m.calls)
+	return calls
+}
+
+// Reset clears the recorded call history
+func (m *MockClient) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+}
+
+func (m *MockClient) doAuth(
+	method string,
+	fn func(request threescale.Request) (*threescale.AuthorizeResult, error),
+	request threescale.Request,
+) (*threescale.AuthorizeResult, error) {
+	if fn == nil {
+		return nil, notConfigured(method)
+	}
+	return fn(request)
+}
+
+func (m *MockClient) record(method string, request threescale.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Method: method, Request: request})
+}
+
+func notConfigured(method string) error {
+	return fmt.Errorf("mock client - no function configured for %s", method)
+}
@@ -0,0 +1,59 @@
+package mock
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/3scale/3scale-go-client/threescale"
+	"github.com/3scale/3scale-go-client/threescale/api"
+)
+
+func TestMockClient(t *testing.T) {
+	request := threescale.Request{
+		Auth:         api.ClientAuth{Type: api.ServiceToken, Value: "st"},
+		Service:      "svc",
+		Transactions: []api.Transaction{{Params: api.Params{UserKey: "key"}}},
+	}
+
+	m := &MockClient{
+		AuthorizeFunc: func(request threescale.Request) (*threescale.AuthorizeResult, error) {
+			return &threescale.AuthorizeResult{Authorized: true}, nil
+		},
+		ReportFunc: func(request threescale.Request) (*threescale.ReportResult, error) {
+			return &threescale.ReportResult{Accepted: true}, nil
+		},
+		Peer: "example.com",
+	}
+
+	authResult, err := m.Authorize(request)
+	if err != nil || !authResult.Authorized {
+		t.Error("expected configured authorize result")
+	}
+
+	reportResult, err := m.Report(request)
+	if err != nil || !reportResult.Accepted {
+		t.Error("expected configured report result")
+	}
+
+	if _, err := m.AuthRep(request); err == nil {
+		t.Error("expected error when AuthRepFunc is not configured")
+	}
+
+	if m.GetPeer() != "example.com" {
+		t.Error("unexpected peer")
+	}
+
+	expect := []Call{
+		{Method: MethodAuthorize, Request: request},
+		{Method: MethodReport, Request: request},
+		{Method: MethodAuthRep, Request: request},
+	}
+	if !reflect.DeepEqual(expect, m.Calls()) {
+		t.Errorf("unexpected call history, wanted %v but got %v", expect, m.Calls())
+	}
+
+	m.Reset()
+	if len(m.Calls()) != 0 {
+		t.Error("expected call history to have been cleared")
+	}
+}
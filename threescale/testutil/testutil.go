@@ -0,0 +1,82 @@
+// Package testutil provides primitives for mocking the HTTP layer when testing code which uses the http Client,
+// allowing responses from 3scale backend to be simulated without a running backend.
+package testutil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// RoundTripFunc implements http.RoundTripper, returning the response produced by the function for every request
+type RoundTripFunc func(req *http.Request) *http.Response
+
+// RoundTrip calls f with the request
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req), nil
+}
+
+// NewRoundTripClient returns an http.Client whose transport is overridden by fn for mocking
+func NewRoundTripClient(fn RoundTripFunc) *http.Client {
+	return &http.Client{
+		Transport: fn,
+	}
+}
+
+// ResponseBuilder builds an http.Response as returned by 3scale backend. Use NewResponse to create a ResponseBuilder
+type ResponseBuilder struct {
+	statusCode int
+	header     http.Header
+	body       string
+}
+
+// NewResponse returns a ResponseBuilder for a response with the provided status code and an empty body
+func NewResponse(statusCode int) *ResponseBuilder {
+	return &ResponseBuilder{
+		statusCode: statusCode,
+		header:     make(http.Header),
+	}
+}
+
+// WithHeader sets the header key to value on the response
+func (rb *ResponseBuilder) WithHeader(key string, value string) *ResponseBuilder {
+	rb.header.Set(key, value)
+	return rb
+}
+
+// WithBody sets the body of the response
+func (rb *ResponseBuilder) WithBody(body string) *ResponseBuilder {
+	rb.body = body
+	return rb
+}
+
+// WithXML sets the body of the response to the provided XML, such as the fixtures available in the fake package,
+// and sets the Content-Type header accordingly
+func (rb *ResponseBuilder) WithXML(body string) *ResponseBuilder {
+	rb.header.Set("Content-Type", "application/xml")
+	return rb.WithBody(body)
+}
+
+// Build returns a new http.Response. Build can be called multiple times, with each response having its own body.
+func (rb *ResponseBuilder) Build() *http.Response {
+	header := make(http.Header, len(rb.header))
+	for k, v := range rb.header {
+		header[k] = append([]string(nil), v...)
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(rb.statusCode) + " " + http.StatusText(rb.statusCode),
+		StatusCode:    rb.statusCode,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewBufferString(rb.body)),
+		ContentLength: int64(len(rb.body)),
+	}
+}
+
+// RespondWith returns a RoundTripFunc which responds to every request with a response built by rb
+func RespondWith(rb *ResponseBuilder) RoundTripFunc {
+	return func(req *http.Request) *http.Response {
+		return rb.Build()
+	}
+}
@@ -0,0 +1,38 @@
+package testutil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestNewRoundTripClient(t *testing.T) {
+	response := NewResponse(http.StatusConflict).
+		WithXML("<status><authorized>false</authorized></status>").
+		WithHeader("3scale-Rejection-Reason", "limits_exceeded")
+
+	client := NewRoundTripClient(RespondWith(response))
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get("http://example.com")
+		if err != nil {
+			t.Fatalf("unexpected error - %s", err.Error())
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusConflict || resp.Status != "409 Conflict" {
+			t.Errorf("unexpected status %s", resp.Status)
+		}
+
+		if string(body) != "<status><authorized>false</authorized></status>" {
+			t.Errorf("unexpected body %s", body)
+		}
+
+		if resp.Header.Get("Content-Type") != "application/xml" ||
+			resp.Header.Get("3scale-Rejection-Reason") != "limits_exceeded" {
+			t.Errorf("unexpected headers %v", resp.Header)
+		}
+	}
+}